@@ -61,6 +61,19 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// DeferStatement schedules Value to be evaluated when the enclosing function
+// call finishes.
+type DeferStatement struct {
+	Token token.Token // DEFER
+	Value Expression
+}
+
+func (ds *DeferStatement) statementNode()       {}
+func (ds *DeferStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DeferStatement) String() string {
+	return ds.Token.Literal + " " + ds.Value.String() + ";"
+}
+
 type ExpressionStatement struct {
 	Token      token.Token // the first token of the expression
 	Expression Expression
@@ -75,6 +88,20 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// FunctionDeclaration binds a function to Name. Declarations are hoisted to
+// the top of their block.
+type FunctionDeclaration struct {
+	Token    token.Token // FUNCTION
+	Name     *Identifier
+	Function *FunctionExpression
+}
+
+func (fd *FunctionDeclaration) statementNode()       {}
+func (fd *FunctionDeclaration) TokenLiteral() string { return fd.Token.Literal }
+func (fd *FunctionDeclaration) String() string {
+	return "fn " + fd.Name.String() + strings.TrimPrefix(fd.Function.String(), "fn")
+}
+
 type BlockStatement struct {
 	Token      token.Token // {
 	Statements []Statement
@@ -95,14 +122,48 @@ func (bs *BlockStatement) String() string {
 type Identifier struct {
 	Token token.Token // IDENT
 	Value string
+	Type  *TypeExpression // optional annotation on bindings and parameters
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
 func (i *Identifier) String() string {
+	if i.Type != nil {
+		return i.Value + ": " + i.Type.String()
+	}
 	return i.Value
 }
 
+// TypeExpression is a type annotation: a named type such as int, an array
+// type [T] or a function type fn(T, U): R.
+type TypeExpression struct {
+	Token      token.Token // IDENT, LBRACKET or FUNCTION
+	Name       string
+	Element    *TypeExpression   // array element type
+	Parameters []*TypeExpression // function parameter types
+	Return     *TypeExpression   // function return type, may be nil
+}
+
+func (te *TypeExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TypeExpression) String() string {
+	switch {
+	case te.Element != nil:
+		return "[" + te.Element.String() + "]"
+	case te.Token.Type == token.FUNCTION:
+		params := []string{}
+		for _, p := range te.Parameters {
+			params = append(params, p.String())
+		}
+		out := "fn(" + strings.Join(params, ", ") + ")"
+		if te.Return != nil {
+			out += ": " + te.Return.String()
+		}
+		return out
+	default:
+		return te.Name
+	}
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
@@ -112,6 +173,15 @@ func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -183,7 +253,9 @@ func (ie *IfExpression) String() string {
 type FunctionExpression struct {
 	Token      token.Token // FUNCTION
 	Parameters []*Identifier
+	ReturnType *TypeExpression // optional annotation
 	Body       *BlockStatement
+	Generator  bool // body, or a function nested in it, contains a yield
 }
 
 func (fe *FunctionExpression) expressionNode()      {}
@@ -198,13 +270,53 @@ func (fe *FunctionExpression) String() string {
 
 	out.WriteString("fn(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") { ")
+	out.WriteString(")")
+	if fe.ReturnType != nil {
+		out.WriteString(": " + fe.ReturnType.String())
+	}
+	out.WriteString(" { ")
 	out.WriteString(fe.Body.String())
 	out.WriteString(" }")
 
 	return out.String()
 }
 
+type MacroLiteral struct {
+	Token      token.Token // MACRO
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out strings.Builder
+
+	params := []string{}
+	for _, param := range ml.Parameters {
+		params = append(params, param.String())
+	}
+
+	out.WriteString("macro(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") { ")
+	out.WriteString(ml.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+type YieldExpression struct {
+	Token token.Token // YIELD
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	return ye.Token.Literal + " " + ye.Value.String()
+}
+
 type CallExpression struct {
 	Token     token.Token // LPAREN
 	Function  Expression  // IDENT or FUNCTION
@@ -251,6 +363,102 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+// MapLiteral lists the Keys of a map along with their Values.
+type MapLiteral struct {
+	Token  token.Token // LBRACE
+	Keys   []Expression
+	Values []Expression
+}
+
+func (ml *MapLiteral) expressionNode()      {}
+func (ml *MapLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MapLiteral) String() string {
+	var out strings.Builder
+
+	pairs := []string{}
+	for i, k := range ml.Keys {
+		pairs = append(pairs, k.String()+": "+ml.Values[i].String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Comprehension builds an array from the Element of every value of Iterable,
+// bound to Variable, for which Condition holds. Condition may be nil.
+type Comprehension struct {
+	Token     token.Token // LBRACKET
+	Element   Expression
+	Variable  *Identifier
+	Iterable  Expression
+	Condition Expression
+}
+
+func (c *Comprehension) expressionNode()      {}
+func (c *Comprehension) TokenLiteral() string { return c.Token.Literal }
+func (c *Comprehension) String() string {
+	var out strings.Builder
+
+	out.WriteString("[")
+	out.WriteString(c.Element.String())
+	out.WriteString(" for ")
+	out.WriteString(c.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(c.Iterable.String())
+	if c.Condition != nil {
+		out.WriteString(" if ")
+		out.WriteString(c.Condition.String())
+	}
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// RangeExpression is a range of integers from Start up to End, excluding
+// End unless Inclusive, counting by Step, or by one when Step is nil.
+type RangeExpression struct {
+	Token     token.Token // RANGE | RANGEEQ
+	Start     Expression
+	End       Expression
+	Step      Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode()      {}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) String() string {
+	var out strings.Builder
+
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.Token.Literal)
+	out.WriteString(re.End.String())
+	if re.Step != nil {
+		out.WriteString(" by ")
+		out.WriteString(re.Step.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// DotExpression looks up Name on Left. Called, it resolves to a method of
+// Left or to a function taking Left as its first argument.
+type DotExpression struct {
+	Token token.Token // DOT
+	Left  Expression
+	Name  *Identifier
+}
+
+func (de *DotExpression) expressionNode()      {}
+func (de *DotExpression) TokenLiteral() string { return de.Token.Literal }
+func (de *DotExpression) String() string {
+	return de.Left.String() + "." + de.Name.String()
+}
+
 type AccessExpression struct {
 	Token token.Token // LBRACKET
 	Array Expression  // IDENT or ARRAY
@@ -278,3 +486,214 @@ func (ae *AssignExpression) String() string {
 		return ae.Name.String() + " " + ae.Token.Literal + " " + ae.Value.String()
 	}
 }
+
+type IndexAssignExpression struct {
+	Token  token.Token // ASSIGN
+	Target *AccessExpression
+	Value  Expression
+}
+
+func (ia *IndexAssignExpression) expressionNode()      {}
+func (ia *IndexAssignExpression) TokenLiteral() string { return ia.Token.Literal }
+func (ia *IndexAssignExpression) String() string {
+	return ia.Target.String() + " " + ia.Token.Literal + " " + ia.Value.String()
+}
+
+type FieldAssignExpression struct {
+	Token  token.Token // ASSIGN
+	Target *DotExpression
+	Value  Expression
+}
+
+func (fa *FieldAssignExpression) expressionNode()      {}
+func (fa *FieldAssignExpression) TokenLiteral() string { return fa.Token.Literal }
+func (fa *FieldAssignExpression) String() string {
+	return fa.Target.String() + " " + fa.Token.Literal + " " + fa.Value.String()
+}
+
+type SpawnExpression struct {
+	Token token.Token // SPAWN
+	Call  *CallExpression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string {
+	return se.Token.Literal + " " + se.Call.String()
+}
+
+type SelectExpression struct {
+	Token token.Token // SELECT
+	Cases []*SelectCase
+}
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SelectExpression) String() string {
+	var out strings.Builder
+
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+
+	out.WriteString("select { ")
+	out.WriteString(strings.Join(cases, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// SelectCase is a single arm of a select: either a recv(ch) or send(ch, v)
+// call, optionally binding the received value to Name, or the default arm
+// when Operation is nil.
+type SelectCase struct {
+	Token     token.Token // first token of the arm
+	Name      *Identifier
+	Operation *CallExpression
+	Body      *BlockStatement
+}
+
+func (sc *SelectCase) String() string {
+	var out strings.Builder
+
+	switch {
+	case sc.Operation == nil:
+		out.WriteString("default")
+	case sc.Name != nil:
+		out.WriteString(sc.Name.String() + " := " + sc.Operation.String())
+	default:
+		out.WriteString(sc.Operation.String())
+	}
+	out.WriteString(" => { ")
+	out.WriteString(sc.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+type EnumLiteral struct {
+	Token    token.Token // ENUM
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+func (el *EnumLiteral) expressionNode()      {}
+func (el *EnumLiteral) TokenLiteral() string { return el.Token.Literal }
+func (el *EnumLiteral) String() string {
+	var out strings.Builder
+
+	variants := []string{}
+	for _, v := range el.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString("enum " + el.Name.String() + " { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// EnumVariant is a single variant of an enum. Variants without Fields are
+// values rather than constructors.
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ev *EnumVariant) String() string {
+	if ev.Fields == nil {
+		return ev.Name.String()
+	}
+
+	fields := []string{}
+	for _, f := range ev.Fields {
+		fields = append(fields, f.String())
+	}
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+type CaseExpression struct {
+	Token   token.Token // CASE
+	Subject Expression
+	Arms    []*CaseArm
+}
+
+func (ce *CaseExpression) expressionNode()      {}
+func (ce *CaseExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CaseExpression) String() string {
+	var out strings.Builder
+
+	arms := []string{}
+	for _, a := range ce.Arms {
+		arms = append(arms, a.String())
+	}
+
+	out.WriteString("case " + ce.Subject.String() + " { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// CaseArm matches the variant called Variant, binding its fields to
+// Bindings, or any value when Variant is the wildcard _.
+type CaseArm struct {
+	Token    token.Token // first token of the arm
+	Variant  *Identifier
+	Bindings []*Identifier
+	Body     *BlockStatement
+}
+
+// IsWildcard reports whether the arm matches every value.
+func (ca *CaseArm) IsWildcard() bool {
+	return ca.Variant.Value == "_"
+}
+
+func (ca *CaseArm) String() string {
+	var out strings.Builder
+
+	out.WriteString(ca.Variant.String())
+	if ca.Bindings != nil {
+		bindings := []string{}
+		for _, b := range ca.Bindings {
+			bindings = append(bindings, b.String())
+		}
+		out.WriteString("(" + strings.Join(bindings, ", ") + ")")
+	}
+	out.WriteString(" => { ")
+	out.WriteString(ca.Body.String())
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// ClassLiteral declares a class with the given methods, inheriting those of
+// Parent unless it is nil. The init method, if any, is the constructor.
+type ClassLiteral struct {
+	Token   token.Token // CLASS
+	Name    *Identifier
+	Parent  *Identifier
+	Methods []*FunctionDeclaration
+}
+
+func (cl *ClassLiteral) expressionNode()      {}
+func (cl *ClassLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *ClassLiteral) String() string {
+	var out strings.Builder
+
+	out.WriteString("class " + cl.Name.String())
+	if cl.Parent != nil {
+		out.WriteString(" < " + cl.Parent.String())
+	}
+	out.WriteString(" { ")
+	methods := []string{}
+	for _, m := range cl.Methods {
+		methods = append(methods, m.String())
+	}
+	out.WriteString(strings.Join(methods, "; "))
+	out.WriteString(" }")
+
+	return out.String()
+}
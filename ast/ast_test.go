@@ -34,3 +34,50 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong; got %q", program.String())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "1"}, Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Token: token.Token{Literal: "2"}, Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{one(), "2"},
+		{&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}}, "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, "(-2)"},
+		{&AccessExpression{Array: one(), Key: one()}, "2[2]"},
+		{&IfExpression{
+			Condition:   one(),
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+		}, "if 2 { 2 } else { 2 }"},
+		{&ReturnStatement{Token: token.Token{Literal: "return"}, Value: one()}, "return 2;"},
+		{&FunctionExpression{Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}}, "fn() { 2 }"},
+		{&ArrayLiteral{Items: []Expression{one(), one()}}, "[2, 2]"},
+		{&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one()}}, "f(2)"},
+		{&AssignExpression{Token: token.Token{Literal: ":="}, Name: &Identifier{Value: "a"}, Value: one()}, "a := 2"},
+	}
+
+	for i, tt := range tests {
+		before := tt.input.String()
+		modified := Modify(tt.input, turnOneIntoTwo)
+
+		if modified.String() != tt.expected {
+			t.Errorf("[%d] wrong modification; expected %q, got %q", i, tt.expected, modified.String())
+		}
+
+		if tt.input.String() != before {
+			t.Errorf("[%d] input was mutated; expected %q, got %q", i, before, tt.input.String())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package ast
+
+type ModifierFunc func(Node) Node
+
+// Modify walks node depth-first, replacing every node with the result of
+// calling modifier on it after its children have been modified. The input
+// tree is left untouched; modified nodes are shallow copies.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+	case *Program:
+		n := *node
+		n.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&n)
+
+	case *ExpressionStatement:
+		n := *node
+		n.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&n)
+
+	case *ReturnStatement:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *DeferStatement:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *BlockStatement:
+		return modifier(modifyBlock(node, modifier))
+
+	case *FunctionDeclaration:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		if fn, ok := modifyExpression(node.Function, modifier).(*FunctionExpression); ok {
+			n.Function = fn
+		}
+		return modifier(&n)
+
+	case *PrefixExpression:
+		n := *node
+		n.Right = modifyExpression(node.Right, modifier)
+		return modifier(&n)
+
+	case *InfixExpression:
+		n := *node
+		n.Left = modifyExpression(node.Left, modifier)
+		n.Right = modifyExpression(node.Right, modifier)
+		return modifier(&n)
+
+	case *IfExpression:
+		n := *node
+		n.Condition = modifyExpression(node.Condition, modifier)
+		n.Consequence = modifyBlock(node.Consequence, modifier)
+		n.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&n)
+
+	case *FunctionExpression:
+		n := *node
+		n.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *MacroLiteral:
+		n := *node
+		n.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		n.Body = modifyBlock(node.Body, modifier)
+		return modifier(&n)
+
+	case *CallExpression:
+		n := *node
+		n.Function = modifyExpression(node.Function, modifier)
+		n.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&n)
+
+	case *ArrayLiteral:
+		n := *node
+		n.Items = modifyExpressions(node.Items, modifier)
+		return modifier(&n)
+
+	case *MapLiteral:
+		n := *node
+		n.Keys = modifyExpressions(node.Keys, modifier)
+		n.Values = modifyExpressions(node.Values, modifier)
+		return modifier(&n)
+
+	case *Comprehension:
+		n := *node
+		n.Element = modifyExpression(node.Element, modifier)
+		n.Variable = modifyIdentifier(node.Variable, modifier)
+		n.Iterable = modifyExpression(node.Iterable, modifier)
+		n.Condition = modifyExpression(node.Condition, modifier)
+		return modifier(&n)
+
+	case *RangeExpression:
+		n := *node
+		n.Start = modifyExpression(node.Start, modifier)
+		n.End = modifyExpression(node.End, modifier)
+		n.Step = modifyExpression(node.Step, modifier)
+		return modifier(&n)
+
+	case *DotExpression:
+		// Name is looked up on the value rather than in scope, so it is
+		// left alone
+		n := *node
+		n.Left = modifyExpression(node.Left, modifier)
+		return modifier(&n)
+
+	case *AccessExpression:
+		n := *node
+		n.Array = modifyExpression(node.Array, modifier)
+		n.Key = modifyExpression(node.Key, modifier)
+		return modifier(&n)
+
+	case *AssignExpression:
+		n := *node
+		n.Name = modifyIdentifier(node.Name, modifier)
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *IndexAssignExpression:
+		n := *node
+		if target, ok := modifyExpression(node.Target, modifier).(*AccessExpression); ok {
+			n.Target = target
+		}
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *FieldAssignExpression:
+		n := *node
+		if target, ok := modifyExpression(node.Target, modifier).(*DotExpression); ok {
+			n.Target = target
+		}
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *ClassLiteral:
+		// method names are looked up on instances rather than in scope, so
+		// only the functions are modified
+		n := *node
+		n.Methods = make([]*FunctionDeclaration, len(node.Methods))
+		for i, m := range node.Methods {
+			method := *m
+			if fn, ok := modifyExpression(m.Function, modifier).(*FunctionExpression); ok {
+				method.Function = fn
+			}
+			n.Methods[i] = &method
+		}
+		return modifier(&n)
+
+	case *YieldExpression:
+		n := *node
+		n.Value = modifyExpression(node.Value, modifier)
+		return modifier(&n)
+
+	case *SpawnExpression:
+		n := *node
+		if call, ok := modifyExpression(node.Call, modifier).(*CallExpression); ok {
+			n.Call = call
+		}
+		return modifier(&n)
+
+	case *SelectExpression:
+		n := *node
+		n.Cases = make([]*SelectCase, len(node.Cases))
+		for i, sc := range node.Cases {
+			c := *sc
+			c.Name = modifyIdentifier(sc.Name, modifier)
+			if sc.Operation != nil {
+				if call, ok := modifyExpression(sc.Operation, modifier).(*CallExpression); ok {
+					c.Operation = call
+				}
+			}
+			c.Body = modifyBlock(sc.Body, modifier)
+			n.Cases[i] = &c
+		}
+		return modifier(&n)
+
+	case *CaseExpression:
+		n := *node
+		n.Subject = modifyExpression(node.Subject, modifier)
+		n.Arms = make([]*CaseArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			a := *arm
+			if arm.Bindings != nil {
+				a.Bindings = modifyIdentifiers(arm.Bindings, modifier)
+			}
+			a.Body = modifyBlock(arm.Body, modifier)
+			n.Arms[i] = &a
+		}
+		return modifier(&n)
+
+	default:
+		return modifier(node)
+	}
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	res := make([]Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		if modified, ok := Modify(stmt, modifier).(Statement); ok {
+			res = append(res, modified)
+		}
+	}
+	return res
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	n := *block
+	n.Statements = modifyStatements(block.Statements, modifier)
+	return &n
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if exp == nil {
+		return nil
+	}
+	if modified, ok := Modify(exp, modifier).(Expression); ok {
+		return modified
+	}
+	return exp
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	res := make([]Expression, len(exps))
+	for i, exp := range exps {
+		res[i] = modifyExpression(exp, modifier)
+	}
+	return res
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
+
+func modifyIdentifiers(idents []*Identifier, modifier ModifierFunc) []*Identifier {
+	res := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		res[i] = modifyIdentifier(ident, modifier)
+	}
+	return res
+}
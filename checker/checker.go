@@ -0,0 +1,704 @@
+// Package checker statically checks type annotations before a program runs.
+// Only code whose types are known from annotations or literals is checked;
+// everything else is treated as unknown and accepted.
+package checker
+
+import (
+	"fmt"
+	"regexp"
+
+	"baboon/ast"
+	"baboon/token"
+)
+
+// Check returns the type errors found in program, formatted like parser
+// errors.
+func Check(program *ast.Program) []string {
+	c := &checker{errors: []string{}, scope: newScope(nil), enums: map[string]*Enum{}, variants: map[string]*Enum{}}
+	c.statements(program.Statements)
+	return c.errors
+}
+
+type scope struct {
+	vars  map[string]Type
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{vars: map[string]Type{}, outer: outer}
+}
+
+func (s *scope) get(name string) (Type, bool) {
+	t, ok := s.vars[name]
+	if !ok && s.outer != nil {
+		return s.outer.get(name)
+	}
+	return t, ok
+}
+
+// builtinTypes lists the builtins whose result type is known.
+var builtinTypes = map[string]Type{
+	"len": &Func{Parameters: []Type{nil}, Return: Int},
+}
+
+// librarySignatures are the types of the library builtins, shared
+// with inference. Builtins with optional or variadic parameters are left out.
+var librarySignatures = map[string]*Func{
+	"split":       {Parameters: []Type{Str, nil}, Return: &Array{Element: Str}},
+	"join":        {Parameters: []Type{&Array{Element: Str}, Str}, Return: Str},
+	"trim":        {Parameters: []Type{Str}, Return: Str},
+	"upper":       {Parameters: []Type{Str}, Return: Str},
+	"lower":       {Parameters: []Type{Str}, Return: Str},
+	"replace":     {Parameters: []Type{Str, nil, Str}, Return: Str},
+	"contains":    {Parameters: []Type{Str, Str}, Return: Bool},
+	"starts_with": {Parameters: []Type{Str, Str}, Return: Bool},
+	"ends_with":   {Parameters: []Type{Str, Str}, Return: Bool},
+	"index_of":    {Parameters: []Type{Str, Str}, Return: Int},
+	"repeat":      {Parameters: []Type{Str, Int}, Return: Str},
+	"chars":       {Parameters: []Type{Str}, Return: &Array{Element: Str}},
+	"keys":        {Parameters: []Type{Map}, Return: &Array{Element: Str}},
+
+	"read_file":   {Parameters: []Type{Str}, Return: Str},
+	"write_file":  {Parameters: []Type{Str, Str}, Return: Void},
+	"append_file": {Parameters: []Type{Str, Str}, Return: Void},
+	"exists":      {Parameters: []Type{Str}, Return: Bool},
+	"list_dir":    {Parameters: []Type{Str}, Return: &Array{Element: Str}},
+	"mkdir":       {Parameters: []Type{Str}, Return: Void},
+	"remove":      {Parameters: []Type{Str}, Return: Void},
+
+	"read_all": {Parameters: []Type{}, Return: Str},
+
+	"regex":    {Parameters: []Type{Str}, Return: Regex},
+	"match":    {Parameters: []Type{Regex, Str}, Return: Bool},
+	"find_all": {Parameters: []Type{Regex, Str}, Return: &Array{Element: Str}},
+	"captures": {Parameters: []Type{Regex, Str}, Return: &Array{Element: Str}},
+
+	"random":   {Parameters: []Type{}, Return: Int},
+	"rand_int": {Parameters: []Type{Int, Int}, Return: Int},
+	"seed":     {Parameters: []Type{Int}, Return: Void},
+
+	"now":             {Parameters: []Type{}, Return: Int},
+	"unix":            {Parameters: []Type{}, Return: Int},
+	"clock":           {Parameters: []Type{}, Return: Int},
+	"sleep":           {Parameters: []Type{Int}, Return: Void},
+	"duration":        {Parameters: []Type{Str}, Return: Int},
+	"format_duration": {Parameters: []Type{Int}, Return: Str},
+
+	"abs":   {Parameters: []Type{nil}, Return: nil},
+	"clamp": {Parameters: []Type{Int, Int, Int}, Return: Int},
+	"pow":   {Parameters: []Type{nil, nil}, Return: nil},
+	"sqrt":  {Parameters: []Type{nil}, Return: nil},
+	"floor": {Parameters: []Type{nil}, Return: Int},
+	"ceil":  {Parameters: []Type{nil}, Return: Int},
+	"round": {Parameters: []Type{nil}, Return: Int},
+	"sin":   {Parameters: []Type{nil}, Return: Float},
+	"cos":   {Parameters: []Type{nil}, Return: Float},
+	"tan":   {Parameters: []Type{nil}, Return: Float},
+	"asin":  {Parameters: []Type{nil}, Return: Float},
+	"acos":  {Parameters: []Type{nil}, Return: Float},
+	"atan":  {Parameters: []Type{nil}, Return: Float},
+	"ln":    {Parameters: []Type{nil}, Return: Float},
+	"float": {Parameters: []Type{nil}, Return: Float},
+	"log":   {Parameters: []Type{Int, Int}, Return: Int},
+	"gcd":   {Parameters: []Type{Int, Int}, Return: Int},
+	"lcm":   {Parameters: []Type{Int, Int}, Return: Int},
+}
+
+// constantTypes are the types of the builtin constants.
+var constantTypes = map[string]Type{
+	"PI": Float,
+	"E":  Float,
+
+	"SECOND": Int,
+	"MINUTE": Int,
+	"HOUR":   Int,
+	"DAY":    Int,
+}
+
+func init() {
+	for name, sig := range librarySignatures {
+		builtinTypes[name] = sig
+	}
+	for name, t := range constantTypes {
+		builtinTypes[name] = t
+	}
+}
+
+type checker struct {
+	errors []string
+	scope  *scope
+	// declared return types of the enclosing functions, innermost last
+	returns []Type
+	// declared enums by name and by the names of their variants
+	enums    map[string]*Enum
+	variants map[string]*Enum
+}
+
+func (c *checker) errorf(line int, column int, format string, args ...interface{}) {
+	msg := fmt.Sprintf("[%d:%d] ", line, column) + fmt.Sprintf(format, args...)
+	c.errors = append(c.errors, msg)
+}
+
+// statements checks stmts and returns the type of the value they evaluate to.
+func (c *checker) statements(stmts []ast.Statement) Type {
+	c.hoist(stmts)
+
+	var result Type = Void
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		t := c.expression(stmt.Value)
+		if len(c.returns) > 0 {
+			want := c.returns[len(c.returns)-1]
+			if !assignable(want, t) {
+				c.errorf(stmt.Token.Line, stmt.Token.Column, "cannot return %s from function returning %s", typeString(t), typeString(want))
+			}
+		}
+		return nil
+	case *ast.BlockStatement:
+		return c.statements(stmt.Statements)
+	case *ast.FunctionDeclaration:
+		// checked by hoist
+		return Void
+	case *ast.DeferStatement:
+		c.expression(stmt.Value)
+		return Void
+	default:
+		return nil
+	}
+}
+
+// hoist checks the functions declared in stmts ahead of the other statements,
+// as they are bound before the block runs. Declarations see each other as
+// unknown, which allows mutual recursion.
+func (c *checker) hoist(stmts []ast.Statement) {
+	decls := []*ast.FunctionDeclaration{}
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FunctionDeclaration); ok {
+			decls = append(decls, decl)
+			c.scope.vars[decl.Name.Value] = nil
+		}
+	}
+
+	for _, decl := range decls {
+		c.scope.vars[decl.Name.Value] = c.function(decl.Function)
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) Type {
+	if block == nil {
+		return Void
+	}
+	return c.statements(block.Statements)
+}
+
+func (c *checker) expression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return Str
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.Identifier:
+		if t, ok := c.scope.get(exp.Value); ok {
+			return t
+		}
+		return builtinTypes[exp.Value]
+
+	case *ast.PrefixExpression:
+		return c.prefix(exp)
+
+	case *ast.InfixExpression:
+		return c.infix(exp)
+
+	case *ast.IfExpression:
+		cond := c.expression(exp.Condition)
+		if cond != nil && cond != Bool {
+			c.errorf(exp.Token.Line, exp.Token.Column, "non-boolean condition in if expression: %s", typeString(cond))
+		}
+		consequence := c.block(exp.Consequence)
+		alternative := c.block(exp.Alternative)
+		if consequence != nil && alternative != nil && consequence.String() == alternative.String() {
+			return consequence
+		}
+		return nil
+
+	case *ast.FunctionExpression:
+		return c.function(exp)
+
+	case *ast.CallExpression:
+		return c.call(exp)
+
+	case *ast.ArrayLiteral:
+		var element Type
+		for i, item := range exp.Items {
+			t := c.expression(item)
+			if i == 0 {
+				element = t
+			} else if element != nil && (t == nil || t.String() != element.String()) {
+				element = nil
+			}
+		}
+		return &Array{Element: element}
+
+	case *ast.MapLiteral:
+		for i, key := range exp.Keys {
+			if t := c.expression(key); !assignable(Str, t) {
+				c.errorf(exp.Token.Line, exp.Token.Column, "invalid map key: %s", t)
+			}
+			c.expression(exp.Values[i])
+		}
+		return Map
+
+	case *ast.AccessExpression:
+		return c.access(exp)
+
+	case *ast.AssignExpression:
+		return c.assign(exp)
+
+	case *ast.IndexAssignExpression:
+		element := c.access(exp.Target)
+		t := c.expression(exp.Value)
+		if !assignable(element, t) {
+			c.errorf(exp.Token.Line, exp.Token.Column, "cannot assign %s to %s element", typeString(t), typeString(element))
+		}
+		return t
+
+	case *ast.YieldExpression:
+		c.expression(exp.Value)
+		return Void
+
+	case *ast.SpawnExpression:
+		c.call(exp.Call)
+		return basicTypes["task"]
+
+	case *ast.SelectExpression:
+		for _, sc := range exp.Cases {
+			if sc.Operation != nil {
+				c.expressions(sc.Operation.Arguments)
+			}
+			c.scope = newScope(c.scope)
+			if sc.Name != nil {
+				c.scope.vars[sc.Name.Value] = nil
+			}
+			c.block(sc.Body)
+			c.scope = c.scope.outer
+		}
+		return nil
+
+	case *ast.Comprehension:
+		var element Type
+		switch iterable := c.expression(exp.Iterable).(type) {
+		case *Array:
+			element = iterable.Element
+		case *Basic:
+			if iterable == Str {
+				element = Str
+			} else if iterable == Range {
+				element = Int
+			}
+		}
+
+		c.scope = newScope(c.scope)
+		c.scope.vars[exp.Variable.Value] = element
+		if exp.Condition != nil {
+			cond := c.expression(exp.Condition)
+			if !assignable(Bool, cond) {
+				c.errorf(exp.Token.Line, exp.Token.Column, "non-boolean condition in comprehension: %s", cond)
+			}
+		}
+		result := &Array{Element: c.expression(exp.Element)}
+		c.scope = c.scope.outer
+		return result
+
+	case *ast.DotExpression:
+		c.expression(exp.Left)
+		return nil
+
+	case *ast.RangeExpression:
+		bounds := []ast.Expression{exp.Start, exp.End}
+		if exp.Step != nil {
+			bounds = append(bounds, exp.Step)
+		}
+		for _, t := range c.expressions(bounds) {
+			if !assignable(Int, t) {
+				c.errorf(exp.Token.Line, exp.Token.Column, "invalid range: %s", exp.String())
+				break
+			}
+		}
+		return Range
+
+	case *ast.EnumLiteral:
+		return c.enum(exp)
+
+	case *ast.ClassLiteral:
+		// instances are not typed, but methods are checked as functions
+		c.scope.vars[exp.Name.Value] = nil
+		c.scope = newScope(c.scope)
+		c.scope.vars["self"] = nil
+		c.scope.vars["super"] = nil
+		for _, m := range exp.Methods {
+			c.function(m.Function)
+		}
+		c.scope = c.scope.outer
+		return nil
+
+	case *ast.FieldAssignExpression:
+		c.expression(exp.Target.Left)
+		return c.expression(exp.Value)
+
+	case *ast.CaseExpression:
+		return c.caseExpression(exp)
+
+	default:
+		return nil
+	}
+}
+
+func (c *checker) expressions(exps []ast.Expression) []Type {
+	types := []Type{}
+	for _, exp := range exps {
+		types = append(types, c.expression(exp))
+	}
+	return types
+}
+
+func (c *checker) prefix(exp *ast.PrefixExpression) Type {
+	right := c.expression(exp.Right)
+
+	want := Int
+	if exp.Operator == "!" {
+		want = Bool
+	} else if right == Float {
+		want = Float
+	}
+	if !assignable(want, right) {
+		c.errorf(exp.Token.Line, exp.Token.Column, "unknown operator: %s%s", exp.Operator, typeString(right))
+	}
+	return want
+}
+
+func (c *checker) infix(exp *ast.InfixExpression) Type {
+	left := c.expression(exp.Left)
+	right := c.expression(exp.Right)
+
+	known := left
+	if known == nil {
+		known = right
+	}
+
+	switch exp.Operator {
+	case "==", "!=":
+		if left != nil && right != nil && left.String() != right.String() {
+			c.errorf(exp.Token.Line, exp.Token.Column, "type mismatch: %s %s %s", left, exp.Operator, right)
+		}
+		return Bool
+	case "in":
+		return Bool
+	}
+
+	// arithmetic and comparisons are on integers unless an operand is known
+	// to be a float, or a string for +
+	var want Type = Int
+	if known == Float || (known == Str && exp.Operator == "+") {
+		want = known
+	}
+	c.operands(exp, left, right, want)
+
+	switch exp.Operator {
+	case "<", ">", "<=", ">=":
+		return Bool
+	default:
+		return want
+	}
+}
+
+// operands reports an error unless both operands may be of type want.
+func (c *checker) operands(exp *ast.InfixExpression, left Type, right Type, want Type) {
+	switch {
+	case left != nil && right != nil && left.String() != right.String():
+		c.errorf(exp.Token.Line, exp.Token.Column, "type mismatch: %s %s %s", left, exp.Operator, right)
+	case !assignable(want, left) || !assignable(want, right):
+		c.errorf(exp.Token.Line, exp.Token.Column, "unknown operator: %s %s %s", typeString(left), exp.Operator, typeString(right))
+	}
+}
+
+func (c *checker) function(exp *ast.FunctionExpression) Type {
+	fn := &Func{Parameters: []Type{}, Return: c.resolve(exp.ReturnType)}
+
+	c.scope = newScope(c.scope)
+	for _, param := range exp.Parameters {
+		t := c.resolve(param.Type)
+		fn.Parameters = append(fn.Parameters, t)
+		c.scope.vars[param.Value] = t
+	}
+
+	c.returns = append(c.returns, fn.Return)
+	result := c.block(exp.Body)
+	c.returns = c.returns[:len(c.returns)-1]
+	c.scope = c.scope.outer
+
+	if exp.Generator {
+		return &Func{Parameters: fn.Parameters, Return: basicTypes["gen"]}
+	}
+
+	if !assignable(fn.Return, result) {
+		c.errorf(exp.Token.Line, exp.Token.Column, "function returning %s evaluates to %s", typeString(fn.Return), typeString(result))
+	}
+
+	return fn
+}
+
+func (c *checker) call(exp *ast.CallExpression) Type {
+	if ident, ok := exp.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+		return basicTypes["quote"]
+	}
+
+	if dot, ok := exp.Function.(*ast.DotExpression); ok {
+		return c.methodCall(exp, dot)
+	}
+
+	callee := c.expression(exp.Function)
+	args := c.expressions(exp.Arguments)
+
+	if ident, ok := exp.Function.(*ast.Identifier); ok && ident.Value == "regex" && callee == builtinTypes["regex"] {
+		c.pattern(exp)
+	}
+
+	ret := c.apply(exp, callee, args)
+	if ident, ok := exp.Function.(*ast.Identifier); ok && numericBuiltins[ident.Value] && callee == builtinTypes[ident.Value] {
+		return numericResult(args)
+	}
+	return ret
+}
+
+// numericBuiltins compute integers from integers and floats from floats.
+var numericBuiltins = map[string]bool{"abs": true, "pow": true, "sqrt": true}
+
+// numericResult is the type returned by a numeric builtin for args, unknown
+// unless all of them are integers or all floats.
+func numericResult(args []Type) Type {
+	if len(args) == 0 || (args[0] != Int && args[0] != Float) {
+		return nil
+	}
+	for _, arg := range args[1:] {
+		if arg != args[0] {
+			return nil
+		}
+	}
+	return args[0]
+}
+
+// pattern reports a regex pattern given as a literal that does not compile
+// at the position of the literal.
+func (c *checker) pattern(exp *ast.CallExpression) {
+	if len(exp.Arguments) != 1 {
+		return
+	}
+	lit, ok := exp.Arguments[0].(*ast.StringLiteral)
+	if !ok {
+		return
+	}
+	if _, err := regexp.Compile(lit.Value); err != nil {
+		c.errorf(lit.Token.Line, lit.Token.Column, "invalid regex: %s", err)
+	}
+}
+
+// methodCall checks v.name(args) as name(v, args) when v is of a known type
+// without fields. Enum variants and maps may hold a function called name.
+func (c *checker) methodCall(exp *ast.CallExpression, dot *ast.DotExpression) Type {
+	receiver := c.expression(dot.Left)
+	args := c.expressions(exp.Arguments)
+
+	switch receiver.(type) {
+	case nil, *Enum:
+		return nil
+	}
+	if receiver == Map {
+		return nil
+	}
+	return c.apply(exp, c.expression(dot.Name), append([]Type{receiver}, args...))
+}
+
+func (c *checker) apply(exp *ast.CallExpression, callee Type, args []Type) Type {
+	switch callee := callee.(type) {
+	case nil:
+		return nil
+	case *Func:
+		if len(args) != len(callee.Parameters) {
+			c.errorf(exp.Token.Line, exp.Token.Column, "wrong number of arguments for %s: expected %d, found %d", exp.Function.String(), len(callee.Parameters), len(args))
+			return callee.Return
+		}
+		for i, arg := range args {
+			if !assignable(callee.Parameters[i], arg) {
+				c.errorf(exp.Token.Line, exp.Token.Column, "cannot use %s as %s in argument %d of %s", typeString(arg), typeString(callee.Parameters[i]), i+1, exp.Function.String())
+			}
+		}
+		return callee.Return
+	default:
+		c.errorf(exp.Token.Line, exp.Token.Column, "not a function: %s", callee)
+		return nil
+	}
+}
+
+func (c *checker) access(exp *ast.AccessExpression) Type {
+	target := c.expression(exp.Array)
+	key := c.expression(exp.Key)
+
+	// maps are indexed by strings and their values are not tracked
+	if key == Str && (target == nil || target == Map) {
+		return nil
+	}
+
+	if !assignable(Int, key) {
+		c.errorf(exp.Token.Line, exp.Token.Column, "invalid argument: %s[%s]", typeString(target), typeString(key))
+		return nil
+	}
+
+	switch target := target.(type) {
+	case *Array:
+		return target.Element
+	case nil:
+		return nil
+	default:
+		if target == Str {
+			return Str
+		}
+		if target == Range {
+			return Int
+		}
+		c.errorf(exp.Token.Line, exp.Token.Column, "invalid argument: %s[%s]", target, typeString(key))
+		return nil
+	}
+}
+
+func (c *checker) assign(exp *ast.AssignExpression) Type {
+	name := exp.Name.Value
+
+	var want Type
+	if exp.Token.Type == token.ASSIGN {
+		want, _ = c.scope.get(name)
+	} else {
+		want = c.resolve(exp.Name.Type)
+		// declare before checking the value so that recursive functions can
+		// refer to themselves
+		c.scope.vars[name] = want
+	}
+
+	got := c.expression(exp.Value)
+	if !assignable(want, got) {
+		c.errorf(exp.Token.Line, exp.Token.Column, "cannot assign %s to %s: %s", typeString(got), name, typeString(want))
+	}
+
+	// constants cannot be rebound, so the type of their value is theirs
+	if exp.Token.Type == token.CONST && exp.Name.Type == nil {
+		c.scope.vars[name] = got
+	}
+
+	return got
+}
+
+func (c *checker) enum(exp *ast.EnumLiteral) Type {
+	enum := &Enum{Name: exp.Name.Value, Fields: map[string]int{}}
+	c.enums[enum.Name] = enum
+	c.scope.vars[enum.Name] = nil
+
+	for _, v := range exp.Variants {
+		name := v.Name.Value
+		if _, ok := enum.Fields[name]; ok {
+			c.errorf(v.Name.Token.Line, v.Name.Token.Column, "duplicate variant %s in enum %s", name, enum.Name)
+			continue
+		}
+		enum.Variants = append(enum.Variants, name)
+		c.variants[name] = enum
+
+		if v.Fields == nil {
+			enum.Fields[name] = -1
+			c.scope.vars[name] = enum
+			continue
+		}
+		enum.Fields[name] = len(v.Fields)
+		c.scope.vars[name] = &Func{Parameters: make([]Type, len(v.Fields)), Return: enum}
+	}
+
+	return nil
+}
+
+// caseExpression checks that the arms of exp name variants of a single enum
+// and, unless there is a wildcard arm, that every variant is handled.
+func (c *checker) caseExpression(exp *ast.CaseExpression) Type {
+	subject := c.expression(exp.Subject)
+
+	enum, _ := subject.(*Enum)
+	if enum == nil {
+		if subject != nil {
+			c.errorf(exp.Token.Line, exp.Token.Column, "case expects an enum variant, got %s", subject)
+		}
+		for _, arm := range exp.Arms {
+			if e, ok := c.variants[arm.Variant.Value]; ok {
+				enum = e
+				break
+			}
+		}
+	}
+
+	var result Type
+	handled := map[string]bool{}
+	wildcard := false
+	for i, arm := range exp.Arms {
+		c.scope = newScope(c.scope)
+		for _, binding := range arm.Bindings {
+			c.scope.vars[binding.Value] = nil
+		}
+		t := c.block(arm.Body)
+		c.scope = c.scope.outer
+
+		if i == 0 {
+			result = t
+		} else if result != nil && (t == nil || t.String() != result.String()) {
+			result = nil
+		}
+
+		if arm.IsWildcard() {
+			wildcard = true
+			continue
+		}
+		if enum == nil {
+			continue
+		}
+
+		fields, ok := enum.Fields[arm.Variant.Value]
+		if fields == -1 {
+			fields = 0
+		}
+		switch {
+		case !ok:
+			c.errorf(arm.Token.Line, arm.Token.Column, "%s has no variant %s", enum.Name, arm.Variant.Value)
+		case fields != len(arm.Bindings):
+			c.errorf(arm.Token.Line, arm.Token.Column, "wrong number of bindings for %s: expected %d, found %d", arm.Variant.Value, fields, len(arm.Bindings))
+		}
+		handled[arm.Variant.Value] = true
+	}
+
+	if enum != nil && !wildcard {
+		for _, v := range enum.Variants {
+			if !handled[v] {
+				c.errorf(exp.Token.Line, exp.Token.Column, "unhandled variant in case: %s.%s", enum.Name, v)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,227 @@
+package checker
+
+import (
+	"testing"
+
+	"baboon/lexer"
+	"baboon/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"x: int := 5", nil},
+		{"x: str := 5", []string{"[1:8] cannot assign int to x: str"}},
+		{`"a" + 1`, []string{"[1:5] type mismatch: str + int"}},
+		{"a := 1; b := a + true", []string{"[1:16] unknown operator: any + bool"}},
+		{"x: [int] :: [1, 2]; y: str := x[0]", []string{"[1:28] cannot assign int to y: str"}},
+		{"x: [int] :: [1, true]", nil},
+		{"x: [int] :: [\"a\", \"b\"]", []string{"[1:10] cannot assign [str] to x: [int]"}},
+		{"f :: fn(a: int): int { a * 2 }; f(1)", nil},
+		{"f :: fn(a: int): int { a * 2 }; f(\"a\")", []string{"[1:34] cannot use str as int in argument 1 of f"}},
+		{"f :: fn(a: int): int { a * 2 }; f(1, 2)", []string{"[1:34] wrong number of arguments for f: expected 1, found 2"}},
+		{"f :: fn(a: str): int { a }", []string{"[1:6] function returning int evaluates to str"}},
+		{"f :: fn(a: str): int { return a }", []string{"[1:24] cannot return str from function returning int"}},
+		{"f :: fn(a: str): int { if true { return 1 }; return 2 }", nil},
+		{"f :: fn(): int {}", []string{"[1:6] function returning int evaluates to void"}},
+		{"n: str := len(\"abc\")", []string{"[1:8] cannot assign int to n: str"}},
+		{"x: foo := 1", []string{"[1:4] unknown type: foo"}},
+		{"x: int := 1; x = \"a\"", []string{"[1:16] cannot assign str to x: int"}},
+		{"x := 1; x = \"a\"", nil},
+		{"if 1 { 2 }", []string{"[1:1] non-boolean condition in if expression: int"}},
+		{"5(1)", []string{"[1:2] not a function: int"}},
+		{"apply :: fn(f: fn(int): int, x: int): int { f(x) }; apply(fn(n: int): int { n }, 1)", nil},
+		{"apply :: fn(f: fn(int): int, x: int): int { f(x) }; apply(fn(s: str): int { 1 }, 1)", []string{"[1:58] cannot use fn(str): int as fn(int): int in argument 1 of apply"}},
+		{"untyped :: fn(a, b) { a + b }; untyped(1, \"x\")", nil},
+		{"fact :: fn(n: int): int { if n < 2 { return 1 }; n * fact(n - 1) }", nil},
+		{"enum S { A(x), B }; f :: fn(s) { case s { A(x) => 1, B => 2 } }", nil},
+		{"enum S { A(x), B, C(y, z) }; f :: fn(s) { case s { A(x) => 1 } }", []string{"[1:43] unhandled variant in case: S.B", "[1:43] unhandled variant in case: S.C"}},
+		{"enum S { A(x), B }; f :: fn(s) { case s { A(x) => 1, _ => 2 } }", nil},
+		{"enum S { A(x), B }; f :: fn(s) { case s { A(x, y) => 1, B => 2, D => 3 } }", []string{"[1:43] wrong number of bindings for A: expected 1, found 2", "[1:65] S has no variant D"}},
+		{"enum S { A(x), B }; s: S := A(1); n: int := case s { A(x) => 1, B => 2 }", nil},
+		{"enum S { A(x), B }; s: S := 1", []string{"[1:26] cannot assign int to s: S"}},
+		{"enum S { A(x), B }; A(1, 2)", []string{"[1:22] wrong number of arguments for A: expected 1, found 2"}},
+		{"case 1 { _ => 1 }", []string{"[1:1] case expects an enum variant, got int"}},
+		{"r: range := 0..10 by 2; n: int := r[0]; b: bool := n in r", nil},
+		{`r :: 0.."a"`, []string{`[1:7] invalid range: (0.."a")`}},
+		{"r :: 0..10; s: str := r[1]", []string{"[1:20] cannot assign int to s: str"}},
+		{"xs: [int] := [x * 2 for x in 0..10 if x > 2]", nil},
+		{`xs: [int] := [c for c in "abc"]`, []string{"[1:11] cannot assign [str] to xs: [int]"}},
+		{"[x for x in [1] if x]", []string{"[1:1] non-boolean condition in comprehension: int"}},
+		{"n: int := twice(2); fn twice(x: int): int { x * 2 }", nil},
+		{`s: str := twice(2); fn twice(x: int): int { x * 2 }`, []string{"[1:8] cannot assign int to s: str"}},
+		{"fn f(x: int): str { x }", []string{"[1:1] function returning str evaluates to int"}},
+		{"fn f() { g(1, 2) }; fn g(x: int): int { x }", nil},
+		{`fn f(): int { defer 1 + "a"; 1 }`, []string{"[1:23] type mismatch: int + str"}},
+		{`n: int := "abc".len()`, nil},
+		{`fn twice(x: int): int { x * 2 }; "a".twice()`, []string{"[1:43] cannot use str as int in argument 1 of \"a\".twice"}},
+		{"fn twice(x: int): int { x * 2 }; s: str := 1.twice()", []string{"[1:41] cannot assign int to s: str"}},
+		{"enum B { B(f) }; B(1).f(true)", nil},
+		{"class A { fn init(x) { self.x = x }; fn get(): int { self.x } }; A(1).get()", nil},
+		{`class A { fn get(): int { "a" } }`, []string{"[1:11] function returning int evaluates to str"}},
+		{`x: [str] :: split("a,b", ","); y: str :: x.join(", ")`, nil},
+		{`x: int :: abs(-1) + pow(2, 3) + round(PI)`, nil},
+		{`x: float :: abs(-1.5) + sqrt(2.0) + PI * sin(1)`, nil},
+		{`x: int :: sqrt(2.0)`, []string{"[1:8] cannot assign float to x: int"}},
+		{`1.5 + 1`, []string{"[1:5] type mismatch: float + int"}},
+		{`-1.5 < 2.5`, nil},
+		{`m: map :: {"a": 1, "b": "c"}; n: int :: len(m); k: [str] :: keys(m); m["a"]`, nil},
+		{`m :: {1: 2}`, []string{"[1:6] invalid map key: int"}},
+		{`m :: {"trim": fn() { 1 }}; n: int :: m.trim()`, nil},
+		{`if exists("a") { write_file("b", read_file("a")) }; names: [str] :: list_dir(".")`, nil},
+		{`write_file("a", 1)`, []string{"[1:11] cannot use int as str in argument 2 of write_file"}},
+		{`x: [int] :: {}`, []string{"[1:10] cannot assign map to x: [int]"}},
+		{`x: str :: sqrt(4)`, []string{"[1:8] cannot assign int to x: str"}},
+		{`x: int :: "a".upper()`, []string{"[1:8] cannot assign str to x: int"}},
+		{`re: regex :: regex("(\w+)=(\d+)"); xs: [str] :: re.captures("a=1"); s: str :: "a1b".replace(re, "$1")`, nil},
+		{`b: bool :: regex("[a-").match(1)`, []string{"[1:18] invalid regex: error parsing regexp: missing closing ]: `[a-`", "[1:30] cannot use int as str in argument 2 of regex(\"[a-\").match"}},
+		{`regex := fn(p) { p }; regex("(")`, nil},
+		{`seed(1); n: int :: rand_int(1, 6) + random()`, nil},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("[%d] parser errors: %v", i, p.Errors())
+			continue
+		}
+
+		errors := Check(program)
+		if len(errors) != len(tt.expected) {
+			t.Errorf("[%d] expected %d errors, got %d: %q", i, len(tt.expected), len(errors), errors)
+			continue
+		}
+
+		for j, expected := range tt.expected {
+			if errors[j] != expected {
+				t.Errorf("[%d] wrong error %d\nexpected:\t%q\ngot:\t\t%q", i, j, expected, errors[j])
+			}
+		}
+	}
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2", ""},
+		{`"a" + "b"`, ""},
+		{`len("abc") + len([1, 2])`, ""},
+		{"len(5)", "[1:4] type mismatch: cannot unify [t2] with int"},
+		{"f :: 5; f(1)", "[1:10] not a function: int"},
+		{`"a" + 1`, "[1:5] type mismatch: cannot unify str with int"},
+		{"if 1 { 2 }", "[1:1] type mismatch: cannot unify bool with int"},
+		{"if true { 1 } else { false }", "[1:1] type mismatch: cannot unify int with bool"},
+		{`id :: fn(x) { x }; id(1); id("a")`, ""},
+		{`id :: fn(x) { x }; pair :: fn(a, b) { [id(a), id(b)] }; pair(1, 2); pair("a", "b")`, ""},
+		{`f := fn(x) { x }; f = fn(x) { x + 1 }; f("a")`, "[1:41] type mismatch: cannot unify int with str"},
+		{`f := fn(x) { x }; f(1); f("a")`, "[1:26] type mismatch: cannot unify int with str"},
+		{`xs := [1, 2]; first(xs) + "a"`, "[1:25] type mismatch: cannot unify str with int"},
+		{`xs := [1, "a"]`, "[1:7] type mismatch: cannot unify int with str"},
+		{`xs := []; ys := append(xs, 1); append(xs, "a")`, "[1:38] type mismatch: cannot unify int with str"},
+		{`first(tail("abc")) + "d"`, ""},
+		{`f :: fn(s) { len(s) }; f("abc") + f([1]) + f(1..3)`, ""},
+		{`f :: fn(s) { first(reverse(tail(s))) }; f("abc") + "d"; f([1, 2]) + 1`, ""},
+		{`f :: fn(s) { [c for c in s].map(fn(c) { c }) }; f("ab").join(",")`, ""},
+		{`add :: fn(a, b) { a + b }; add(1, 2) + 1; add("a", "b") + "c"`, ""},
+		{`add :: fn(a, b) { a + b }; add(1, "a")`, "[1:31] type mismatch: cannot unify int with str"},
+		{"fact :: fn(n) { if n < 2 { return 1 }; n * fact(n - 1) }; fact(5)", ""},
+		{`fact :: fn(n) { if n < 2 { return 1 }; n * fact(n - 1) }; fact("x")`, "[1:63] type mismatch: cannot unify int with str"},
+		{"f :: fn(g) { g(1) }; f(fn(x) { x + 1 }); f(5)", "[1:43] type mismatch: cannot unify fn(int): t8 with int"},
+		{"add :: fn(a, b) { a + b }; add(1)", "[1:31] wrong number of arguments for add: expected 2, found 1"},
+		{"fn(x) { x(x) }", "[1:10] infinite type: t2 occurs in fn(t2): t3"},
+		{`print(1, "a", true)`, ""},
+		{"x := 1; x = true", "[1:11] type mismatch: cannot unify int with bool"},
+		{`
+even :: fn(n) { if n == 0 { return true }; odd(n - 1) }
+odd :: fn(n) { if n == 0 { return false }; even(n - 1) }
+even(10)
+`, ""},
+		{`
+map :: fn(arr, f) {
+	aux :: fn(acc, arr) {
+		if len(arr) == 0 {
+			return acc
+		}
+		aux(append(acc, f(first(arr))), tail(arr))
+	}
+	aux([], arr)
+}
+lens :: map(["a", "bc"], fn(s) { len(s) })
+map(lens, fn(n) { n * 2 })
+`, ""},
+		{`enum O { Some(v), None }; a := Some(1); b := Some("a"); a == b`, ""},
+		{"enum O { Some(v), None }; case None { Some(v) => 1, None => 2 } + 1", ""},
+		{`enum O { Some(v), None }; case None { Some(v) => 1, None => "a" }`, "[1:53] type mismatch: cannot unify int with str"},
+		{"enum O { Some(v), None }; case 1 { Some(v) => 1 }", "[1:27] type mismatch: cannot unify O with int"},
+		{"r :: 1..=10 by 2; len(r) + first(tail(r)) + r[0]", ""},
+		{`r :: 1..10; "a" in r`, "[1:17] type mismatch: cannot unify int with str"},
+		{`"a" in ["a"]; 1 in "abc"`, "[1:17] type mismatch: cannot unify str with int"},
+		{`0..true`, "[1:2] type mismatch: cannot unify int with bool"},
+		{`[x + 1 for x in ["a"]]`, "[1:4] type mismatch: cannot unify str with int"},
+		{`ys :: [c + "!" for c in "ab" if c != "a"]; first(ys) + "?"`, ""},
+		{"[x for x in 5]", "[1:1] type mismatch: cannot unify [t1] with int"},
+		{`
+n :: is-even(10)
+fn is-even(n) { if n == 0 { return true }; is-odd(n - 1) }
+fn is-odd(n) { if n == 0 { return false }; is-even(n - 1) }
+`, ""},
+		{`fn is-even(n) { if n == 0 { return true }; is-odd(n - 1) }; fn is-odd(n) { if n == 0 { return 1 }; is-even(n - 1) }`, "[1:61] type mismatch: cannot unify int with bool"},
+		{`fn id(x) { x }; id(1) + 1; id("a") + "b"`, ""},
+		{`fn f(x) { x + 1 }; f("a")`, "[1:21] type mismatch: cannot unify int with str"},
+		{`fn f(x) { x + 1 }; "a".f()`, "[1:25] type mismatch: cannot unify int with str"},
+		{`fn map(xs, f) { [f(x) for x in xs] }; ["a", "bc"].map(fn(s) { s.len() }).map(fn(n) { n * 2 })`, ""},
+		{"fn g(v) { v.anything(1) }; g(1)", ""},
+		{"class A { fn init(x) { self.x = x }; fn get() { self.x + 1 } }; A(1).get().len()", ""},
+		{`class A { fn f() { 1 + "a" } }`, "[1:22] type mismatch: cannot unify str with int"},
+		{`fn f(s) { trim(s) }; f(" a ").len() + index_of("ab", "b")`, ""},
+		{`fn f(s) { repeat(s, 2) }; f(1)`, "[1:28] type mismatch: cannot unify str with int"},
+		{`(0..3).filter(fn(x) { x > 1 }).map(fn(x) { x * 2 }).reduce(fn(a, x) { a + x }, 0) + 1`, ""},
+		{`"ab".map(fn(c) { c.upper() }).sort(fn(a, b) { a != b }).join(",")`, ""},
+		{`[1, 2].map(fn(x) { x * 2 }).join(",")`, "[1:33] type mismatch: cannot unify [str] with [int]"},
+		{`max(1, 2) + min([3, 4]) + gcd(6, 4) * round(E)`, ""},
+		{`f :: fn(x) { x * 2.0 }; f(1.5) + sin(1) + sqrt(2.0) + pow(PI, 2.0)`, ""},
+		{`f :: fn(x) { x * 2.0 }; f(1)`, "[1:26] type mismatch: cannot unify float with int"},
+		{`abs(1.5) + 1`, "[1:10] type mismatch: cannot unify float with int"},
+		{`map([1.5, 2.5], floor)[0] + ceil(1) + round(0.5)`, ""},
+		{`max(1, "a")`, "[1:4] type mismatch: cannot unify int with str"},
+		{`m :: {"a": [1]}; m["a"].len() + len(m) + values(m).len()`, ""},
+		{`m :: {"trim": fn() { 1 }}; m.trim() + 1`, ""},
+		{`fn get(cfg) { cfg["port"] }; get([1])`, "[1:33] type mismatch: cannot unify map with [int]"},
+		{`sprintf("%d %s", 1, "a").len() + len(sprintf("x"))`, ""},
+		{`format_time(now() + 2 * HOUR, "15:04").len() + parse_time("x") - clock()`, ""},
+		{`parse_time(1)`, "[1:11] type mismatch: cannot unify str with int"},
+		{`re :: regex("a+"); "xaay".split(re).join(re.find_all("aa")[0]).len() + replace("a", re, "b").len()`, ""},
+		{`re :: regex("a+"); if re.match("a") { re.captures("a") } else { split("a", "b") }`, ""},
+		{`regex("a").match(1)`, "[1:17] type mismatch: cannot unify str with int"},
+		{`seed(1); rand_int(1, 6) + random() + choice([1, 2]) + shuffle(0..3)[0] + choice("ab").len()`, ""},
+		{`choice(["a"]) + 1`, "[1:15] type mismatch: cannot unify str with int"},
+		{`sprintf(1, 2)`, "[1:8] type mismatch: cannot unify str with int"},
+		{`m :: {"a": 1}; 1 in m`, "[1:18] type mismatch: cannot unify str with int"},
+		{`[1].reduce(fn(a, x) { a + x }, "")`, "[1:11] type mismatch: cannot unify int with str"},
+		{`[[1]].sort_by(fn(x) { len(x) }).find(fn(x) { x == 1 })`, "[1:37] type mismatch: cannot unify fn([int]): bool with fn(int): bool"},
+	}
+
+	for i, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Errorf("[%d] parser errors: %v", i, p.Errors())
+			continue
+		}
+
+		err := Infer(program)
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("[%d] unexpected error: %s", i, err)
+		case tt.expected != "" && err == nil:
+			t.Errorf("[%d] expected error %q, got none", i, tt.expected)
+		case err != nil && err.Error() != tt.expected:
+			t.Errorf("[%d] wrong error\nexpected:\t%q\ngot:\t\t%q", i, tt.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,1069 @@
+package checker
+
+import (
+	"fmt"
+
+	"baboon/ast"
+	"baboon/token"
+)
+
+// Var is a type variable of the inference pass. Bound variables point to the
+// type they were unified with.
+type Var struct {
+	id       int
+	instance Type
+}
+
+func (v *Var) String() string {
+	if v.instance != nil {
+		return v.instance.String()
+	}
+	return fmt.Sprintf("t%d", v.id)
+}
+
+// scheme is a type generalised over vars, as produced by let-polymorphism.
+type scheme struct {
+	vars []*Var
+	t    Type
+}
+
+type inferScope struct {
+	vars  map[string]*scheme
+	outer *inferScope
+}
+
+func (s *inferScope) get(name string) (*scheme, bool) {
+	sc, ok := s.vars[name]
+	if !ok && s.outer != nil {
+		return s.outer.get(name)
+	}
+	return sc, ok
+}
+
+// inferenceError aborts the pass at the first unification failure.
+type inferenceError struct {
+	msg string
+}
+
+// Infer runs Hindley-Milner type inference over program, which needs no
+// annotations, and returns the first unification failure. Constant bindings
+// of function literals are generalised, so helpers like `id :: fn(x) { x }` can
+// be used at several types. Operators and builtins overloaded on strings,
+// ranges and arrays pick a variant only if the operand is already known to be
+// one of them, and leave operands of unknown type unconstrained otherwise.
+func Infer(program *ast.Program) (err error) {
+	in := &inferrer{scope: &inferScope{vars: map[string]*scheme{}}, variants: map[string]*Enum{}}
+
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(inferenceError)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("%s", failure.msg)
+		}
+	}()
+
+	in.statements(program.Statements)
+	return nil
+}
+
+type inferrer struct {
+	scope   *inferScope
+	nextVar int
+	// return types of the enclosing functions, innermost last
+	returns []Type
+	// declared enums by the names of their variants
+	variants map[string]*Enum
+}
+
+func (in *inferrer) fresh() *Var {
+	in.nextVar += 1
+	return &Var{id: in.nextVar}
+}
+
+func (in *inferrer) fail(tok token.Token, format string, args ...interface{}) {
+	msg := fmt.Sprintf("[%d:%d] ", tok.Line, tok.Column) + fmt.Sprintf(format, args...)
+	panic(inferenceError{msg: msg})
+}
+
+func prune(t Type) Type {
+	if v, ok := t.(*Var); ok && v.instance != nil {
+		v.instance = prune(v.instance)
+		return v.instance
+	}
+	return t
+}
+
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *Array:
+		return occurs(v, t.Element)
+	case *Func:
+		for _, p := range t.Parameters {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Return)
+	default:
+		return false
+	}
+}
+
+func (in *inferrer) unify(tok token.Token, a Type, b Type) {
+	if v, ok := prune(a).(*Var); ok && v != prune(b) && occurs(v, b) {
+		in.fail(tok, "infinite type: %s occurs in %s", v, prune(b))
+	}
+	if v, ok := prune(b).(*Var); ok && v != prune(a) && occurs(v, a) {
+		in.fail(tok, "infinite type: %s occurs in %s", v, prune(a))
+	}
+	if !in.tryUnify(a, b) {
+		in.fail(tok, "type mismatch: cannot unify %s with %s", prune(a), prune(b))
+	}
+}
+
+func (in *inferrer) tryUnify(a Type, b Type) bool {
+	a, b = prune(a), prune(b)
+
+	if v, ok := a.(*Var); ok {
+		if v == b {
+			return true
+		}
+		if occurs(v, b) {
+			return false
+		}
+		v.instance = b
+		return true
+	}
+	if _, ok := b.(*Var); ok {
+		return in.tryUnify(b, a)
+	}
+
+	switch a := a.(type) {
+	case *Basic:
+		other, ok := b.(*Basic)
+		return ok && other.Name == a.Name
+	case *Enum:
+		other, ok := b.(*Enum)
+		return ok && other.Name == a.Name
+	case *Array:
+		other, ok := b.(*Array)
+		return ok && in.tryUnify(a.Element, other.Element)
+	case *Func:
+		other, ok := b.(*Func)
+		if !ok || len(other.Parameters) != len(a.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !in.tryUnify(a.Parameters[i], other.Parameters[i]) {
+				return false
+			}
+		}
+		return in.tryUnify(a.Return, other.Return)
+	default:
+		return false
+	}
+}
+
+func (in *inferrer) instantiate(sc *scheme) Type {
+	if len(sc.vars) == 0 {
+		return sc.t
+	}
+
+	mapping := map[*Var]Type{}
+	for _, v := range sc.vars {
+		mapping[v] = in.fresh()
+	}
+
+	var copyType func(Type) Type
+	copyType = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Var:
+			if m, ok := mapping[t]; ok {
+				return m
+			}
+			return t
+		case *Array:
+			return &Array{Element: copyType(t.Element)}
+		case *Func:
+			params := []Type{}
+			for _, p := range t.Parameters {
+				params = append(params, copyType(p))
+			}
+			return &Func{Parameters: params, Return: copyType(t.Return)}
+		default:
+			return t
+		}
+	}
+
+	return copyType(sc.t)
+}
+
+func freeVars(t Type, acc map[*Var]bool) {
+	switch t := prune(t).(type) {
+	case *Var:
+		acc[t] = true
+	case *Array:
+		freeVars(t.Element, acc)
+	case *Func:
+		for _, p := range t.Parameters {
+			freeVars(p, acc)
+		}
+		freeVars(t.Return, acc)
+	}
+}
+
+func (in *inferrer) generalize(t Type) *scheme {
+	inScope := map[*Var]bool{}
+	for s := in.scope; s != nil; s = s.outer {
+		for _, sc := range s.vars {
+			free := map[*Var]bool{}
+			freeVars(sc.t, free)
+			for _, v := range sc.vars {
+				delete(free, v)
+			}
+			for v := range free {
+				inScope[v] = true
+			}
+		}
+	}
+
+	free := map[*Var]bool{}
+	freeVars(t, free)
+
+	sc := &scheme{t: t}
+	for v := range free {
+		if !inScope[v] {
+			sc.vars = append(sc.vars, v)
+		}
+	}
+	return sc
+}
+
+func (in *inferrer) bind(name string, t Type) {
+	in.scope.vars[name] = &scheme{t: t}
+}
+
+func (in *inferrer) enter() {
+	in.scope = &inferScope{vars: map[string]*scheme{}, outer: in.scope}
+}
+
+func (in *inferrer) leave() {
+	in.scope = in.scope.outer
+}
+
+func (in *inferrer) statements(stmts []ast.Statement) Type {
+	in.hoist(stmts)
+
+	var result Type = Void
+	for _, stmt := range stmts {
+		result = in.statement(stmt)
+	}
+	return result
+}
+
+func (in *inferrer) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return in.expression(stmt.Expression)
+	case *ast.ReturnStatement:
+		t := in.expression(stmt.Value)
+		if len(in.returns) > 0 {
+			in.unify(stmt.Token, in.returns[len(in.returns)-1], t)
+		}
+		// control does not continue past a return, so it fits any context
+		return in.fresh()
+	case *ast.BlockStatement:
+		return in.statements(stmt.Statements)
+	case *ast.FunctionDeclaration:
+		// inferred by hoist
+		return Void
+	case *ast.DeferStatement:
+		in.expression(stmt.Value)
+		return Void
+	default:
+		return in.fresh()
+	}
+}
+
+// hoist infers the functions declared in stmts as a single recursive group:
+// they are monomorphic within the group and generalised after it.
+func (in *inferrer) hoist(stmts []ast.Statement) {
+	decls := []*ast.FunctionDeclaration{}
+	types := []Type{}
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*ast.FunctionDeclaration); ok {
+			t := in.fresh()
+			in.bind(decl.Name.Value, t)
+			decls = append(decls, decl)
+			types = append(types, t)
+		}
+	}
+
+	for i, decl := range decls {
+		in.unify(decl.Token, types[i], in.function(decl.Function, nil))
+	}
+
+	for _, decl := range decls {
+		delete(in.scope.vars, decl.Name.Value)
+	}
+	for i, decl := range decls {
+		in.scope.vars[decl.Name.Value] = in.generalize(types[i])
+	}
+}
+
+func (in *inferrer) expression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return Str
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.Identifier:
+		if sc, ok := in.scope.get(exp.Value); ok {
+			return in.instantiate(sc)
+		}
+		if sc, ok := builtinSchemes[exp.Value]; ok {
+			return in.instantiate(sc(in))
+		}
+		// other builtins and undefined names are left unconstrained
+		return in.fresh()
+
+	case *ast.PrefixExpression:
+		right := in.expression(exp.Right)
+		want := numeric(right)
+		if exp.Operator == "!" {
+			want = Bool
+		}
+		in.unify(exp.Token, want, right)
+		return want
+
+	case *ast.InfixExpression:
+		return in.infix(exp)
+
+	case *ast.IfExpression:
+		in.unify(exp.Token, Bool, in.expression(exp.Condition))
+		consequence := in.statements(exp.Consequence.Statements)
+		if exp.Alternative == nil {
+			return Void
+		}
+		alternative := in.statements(exp.Alternative.Statements)
+		in.unify(exp.Token, consequence, alternative)
+		return consequence
+
+	case *ast.FunctionExpression:
+		return in.function(exp, nil)
+
+	case *ast.CallExpression:
+		return in.call(exp)
+
+	case *ast.ArrayLiteral:
+		element := Type(in.fresh())
+		for _, item := range exp.Items {
+			in.unify(exp.Token, element, in.expression(item))
+		}
+		return &Array{Element: element}
+
+	case *ast.MapLiteral:
+		// values are not tracked, so maps may mix them
+		for i, key := range exp.Keys {
+			in.unify(exp.Token, Str, in.expression(key))
+			in.expression(exp.Values[i])
+		}
+		return Map
+
+	case *ast.AccessExpression:
+		return in.access(exp)
+
+	case *ast.AssignExpression:
+		return in.assign(exp)
+
+	case *ast.IndexAssignExpression:
+		element := in.access(exp.Target)
+		value := in.expression(exp.Value)
+		in.unify(exp.Token, element, value)
+		return value
+
+	case *ast.YieldExpression:
+		in.expression(exp.Value)
+		return Void
+
+	case *ast.SpawnExpression:
+		in.call(exp.Call)
+		return basicTypes["task"]
+
+	case *ast.SelectExpression:
+		result := Type(in.fresh())
+		for _, sc := range exp.Cases {
+			if sc.Operation != nil {
+				for _, arg := range sc.Operation.Arguments {
+					in.expression(arg)
+				}
+			}
+			in.enter()
+			if sc.Name != nil {
+				in.bind(sc.Name.Value, in.fresh())
+			}
+			in.unify(sc.Token, result, in.statements(sc.Body.Statements))
+			in.leave()
+		}
+		return result
+
+	case *ast.EnumLiteral:
+		in.enum(exp)
+		return in.fresh()
+
+	case *ast.CaseExpression:
+		subject := in.expression(exp.Subject)
+		for _, arm := range exp.Arms {
+			if enum, ok := in.variants[arm.Variant.Value]; ok {
+				in.unify(exp.Token, enum, subject)
+				break
+			}
+		}
+
+		result := Type(in.fresh())
+		for _, arm := range exp.Arms {
+			in.enter()
+			for _, binding := range arm.Bindings {
+				in.bind(binding.Value, in.fresh())
+			}
+			in.unify(arm.Token, result, in.statements(arm.Body.Statements))
+			in.leave()
+		}
+		return result
+
+	case *ast.Comprehension:
+		iterable := in.expression(exp.Iterable)
+
+		var element Type
+		switch prune(iterable) {
+		case Str:
+			element = Str
+		case Range:
+			element = Int
+		case basicTypes["gen"], basicTypes["chan"]:
+			element = in.fresh()
+		default:
+			if unresolved(iterable) {
+				element = in.fresh()
+				break
+			}
+			element = in.fresh()
+			in.unify(exp.Token, &Array{Element: element}, iterable)
+		}
+
+		in.enter()
+		in.bind(exp.Variable.Value, element)
+		if exp.Condition != nil {
+			in.unify(exp.Token, Bool, in.expression(exp.Condition))
+		}
+		result := &Array{Element: in.expression(exp.Element)}
+		in.leave()
+		return result
+
+	case *ast.DotExpression:
+		// fields are not tracked
+		in.expression(exp.Left)
+		return in.fresh()
+
+	case *ast.FieldAssignExpression:
+		in.expression(exp.Target.Left)
+		return in.expression(exp.Value)
+
+	case *ast.ClassLiteral:
+		// instances are not typed, so the class and self are unconstrained
+		in.bind(exp.Name.Value, in.fresh())
+		in.enter()
+		in.bind("self", in.fresh())
+		in.bind("super", in.fresh())
+		for _, m := range exp.Methods {
+			in.function(m.Function, nil)
+		}
+		in.leave()
+		return in.fresh()
+
+	case *ast.RangeExpression:
+		in.unify(exp.Token, Int, in.expression(exp.Start))
+		in.unify(exp.Token, Int, in.expression(exp.End))
+		if exp.Step != nil {
+			in.unify(exp.Token, Int, in.expression(exp.Step))
+		}
+		return Range
+
+	default:
+		return in.fresh()
+	}
+}
+
+// enum binds the constructors of exp. Fields are not typed, so constructors
+// accept values of any type and case bindings are unconstrained.
+func (in *inferrer) enum(exp *ast.EnumLiteral) {
+	enum := &Enum{Name: exp.Name.Value, Fields: map[string]int{}}
+
+	for _, v := range exp.Variants {
+		name := v.Name.Value
+		enum.Variants = append(enum.Variants, name)
+		in.variants[name] = enum
+
+		if v.Fields == nil {
+			enum.Fields[name] = -1
+			in.bind(name, enum)
+			continue
+		}
+		enum.Fields[name] = len(v.Fields)
+
+		fields := []*Var{}
+		params := []Type{}
+		for range v.Fields {
+			field := in.fresh()
+			fields = append(fields, field)
+			params = append(params, field)
+		}
+		in.scope.vars[name] = &scheme{vars: fields, t: &Func{Parameters: params, Return: enum}}
+	}
+}
+
+func (in *inferrer) infix(exp *ast.InfixExpression) Type {
+	left := in.expression(exp.Left)
+	right := in.expression(exp.Right)
+
+	switch exp.Operator {
+	case "==", "!=":
+		in.unify(exp.Token, left, right)
+		return Bool
+	case "in":
+		switch r := prune(right).(type) {
+		case *Array:
+			in.unify(exp.Token, r.Element, left)
+		case *Basic:
+			if r == Range {
+				in.unify(exp.Token, Int, left)
+			} else if r == Map {
+				in.unify(exp.Token, Str, left)
+			} else {
+				in.unify(exp.Token, r, left)
+			}
+		}
+		return Bool
+	case "<", ">", "<=", ">=":
+		want := numeric(left, right)
+		in.unify(exp.Token, want, left)
+		in.unify(exp.Token, want, right)
+		return Bool
+	case "+":
+		if prune(left) == Str || prune(right) == Str {
+			in.unify(exp.Token, Str, left)
+			in.unify(exp.Token, Str, right)
+			return Str
+		}
+		if unresolved(left) && unresolved(right) {
+			// strings or numbers alike
+			in.unify(exp.Token, left, right)
+			return left
+		}
+		fallthrough
+	default:
+		want := numeric(left, right)
+		in.unify(exp.Token, want, left)
+		in.unify(exp.Token, want, right)
+		return want
+	}
+}
+
+// numeric is the type of the operands of arithmetic: floats if any of types
+// is already known to be one, integers otherwise.
+func numeric(types ...Type) Type {
+	for _, t := range types {
+		if prune(t) == Float {
+			return Float
+		}
+	}
+	return Int
+}
+
+// unresolved reports whether t is a type variable not unified with a type
+// yet, such as the type of a parameter not used so far.
+func unresolved(t Type) bool {
+	_, ok := prune(t).(*Var)
+	return ok
+}
+
+// function infers the type of a function literal. If the literal is passed
+// where a function type is expected, its parameters start out with the
+// expected types so that overloaded operations in the body resolve.
+func (in *inferrer) function(exp *ast.FunctionExpression, expected Type) Type {
+	fn := &Func{Parameters: []Type{}, Return: in.fresh()}
+
+	want, ok := prune(expected).(*Func)
+	if !ok || len(want.Parameters) != len(exp.Parameters) {
+		want = nil
+	}
+
+	in.enter()
+	for i, param := range exp.Parameters {
+		t := Type(in.fresh())
+		if want != nil {
+			t = want.Parameters[i]
+		}
+		fn.Parameters = append(fn.Parameters, t)
+		in.bind(param.Value, t)
+	}
+
+	in.returns = append(in.returns, fn.Return)
+	body := in.statements(exp.Body.Statements)
+	in.returns = in.returns[:len(in.returns)-1]
+	in.leave()
+
+	if exp.Generator {
+		return &Func{Parameters: fn.Parameters, Return: basicTypes["gen"]}
+	}
+
+	in.unify(exp.Token, fn.Return, body)
+	return fn
+}
+
+func (in *inferrer) call(exp *ast.CallExpression) Type {
+	if dot, ok := exp.Function.(*ast.DotExpression); ok {
+		return in.methodCall(exp, dot)
+	}
+	return in.callWith(exp, exp.Function, nil, exp.Arguments)
+}
+
+// methodCall infers v.name(args) as name(v, args). Receivers of unknown type,
+// enum variants and maps may have fields of that name instead, which are not
+// tracked, so such calls are left unconstrained.
+func (in *inferrer) methodCall(exp *ast.CallExpression, dot *ast.DotExpression) Type {
+	receiver := in.expression(dot.Left)
+
+	r := prune(receiver)
+	_, isVar := r.(*Var)
+	_, isEnum := r.(*Enum)
+	if !isVar && !isEnum && r != Map {
+		return in.callWith(exp, dot.Name, receiver, exp.Arguments)
+	}
+
+	for _, arg := range exp.Arguments {
+		in.expression(arg)
+	}
+	return in.fresh()
+}
+
+// callWith infers a call of function with arguments, preceded by receiver
+// unless it is nil.
+func (in *inferrer) callWith(exp *ast.CallExpression, function ast.Expression, receiver Type, arguments []ast.Expression) Type {
+	leading := []Type{}
+	if receiver != nil {
+		leading = append(leading, receiver)
+	}
+
+	args := func() []Type {
+		types := append([]Type{}, leading...)
+		for _, arg := range arguments {
+			types = append(types, in.expression(arg))
+		}
+		return types
+	}
+
+	if ident, ok := function.(*ast.Identifier); ok {
+		if _, shadowed := in.scope.get(ident.Value); !shadowed {
+			if ident.Value == "quote" && receiver == nil {
+				return basicTypes["quote"]
+			}
+			if sig, ok := builtinCalls[ident.Value]; ok {
+				args := args()
+				return in.apply(exp, sig(in, args), args)
+			}
+		}
+	}
+
+	callee := in.expression(function)
+
+	// check arguments against known parameter types one by one, so that
+	// function literals see what earlier arguments imply
+	if fn, ok := prune(callee).(*Func); ok && len(fn.Parameters) == len(leading)+len(arguments) {
+		for i, t := range leading {
+			in.unify(exp.Token, fn.Parameters[i], t)
+		}
+		for i, arg := range arguments {
+			param := fn.Parameters[len(leading)+i]
+
+			var t Type
+			if lit, ok := arg.(*ast.FunctionExpression); ok {
+				t = in.function(lit, param)
+			} else {
+				t = in.expression(arg)
+			}
+			in.unify(exp.Token, param, t)
+		}
+		return fn.Return
+	}
+
+	return in.apply(exp, callee, args())
+}
+
+func (in *inferrer) apply(exp *ast.CallExpression, callee Type, args []Type) Type {
+	switch fn := prune(callee).(type) {
+	case *Func:
+		if len(fn.Parameters) != len(args) {
+			in.fail(exp.Token, "wrong number of arguments for %s: expected %d, found %d", exp.Function.String(), len(fn.Parameters), len(args))
+		}
+		for i, arg := range args {
+			in.unify(exp.Token, fn.Parameters[i], arg)
+		}
+		return fn.Return
+	case *Var:
+		ret := in.fresh()
+		in.unify(exp.Token, fn, &Func{Parameters: args, Return: ret})
+		return ret
+	default:
+		in.fail(exp.Token, "not a function: %s", fn)
+		return nil
+	}
+}
+
+func (in *inferrer) access(exp *ast.AccessExpression) Type {
+	target := in.expression(exp.Array)
+	key := in.expression(exp.Key)
+
+	if prune(key) == Str || prune(target) == Map {
+		in.unify(exp.Token, Map, target)
+		in.unify(exp.Token, Str, key)
+		return in.fresh()
+	}
+	in.unify(exp.Token, Int, key)
+
+	if prune(target) == Str {
+		return Str
+	}
+	if prune(target) == Range {
+		return Int
+	}
+
+	element := in.fresh()
+	in.unify(exp.Token, &Array{Element: element}, target)
+	return element
+}
+
+func (in *inferrer) assign(exp *ast.AssignExpression) Type {
+	name := exp.Name.Value
+
+	if exp.Token.Type == token.ASSIGN {
+		value := in.expression(exp.Value)
+		if sc, ok := in.scope.get(name); ok {
+			in.unify(exp.Token, in.instantiate(sc), value)
+		}
+		return value
+	}
+
+	// the name is monomorphic within its own definition, allowing recursion
+	t := in.fresh()
+	in.bind(name, t)
+	value := in.expression(exp.Value)
+	in.unify(exp.Token, t, value)
+
+	// only constant function literals are generalised, as other values may
+	// be mutated, and variables reassigned, at a single type
+	if _, ok := exp.Value.(*ast.FunctionExpression); ok && exp.Token.Type == token.CONST {
+		delete(in.scope.vars, name)
+		in.scope.vars[name] = in.generalize(t)
+	}
+
+	return value
+}
+
+// builtinSchemes are the types of builtins used as values. Numeric builtins
+// are taken to be on integers, or floats if computed in floating point.
+var builtinSchemes = map[string]func(in *inferrer) *scheme{
+	"abs":   numericScheme(Int, Int),
+	"sqrt":  numericScheme(Int, Int),
+	"pow":   numericScheme(Int, Int, Int),
+	"floor": numericScheme(Float, Int),
+	"ceil":  numericScheme(Float, Int),
+	"round": numericScheme(Float, Int),
+	"sin":   numericScheme(Float, Float),
+	"cos":   numericScheme(Float, Float),
+	"tan":   numericScheme(Float, Float),
+	"asin":  numericScheme(Float, Float),
+	"acos":  numericScheme(Float, Float),
+	"atan":  numericScheme(Float, Float),
+	"ln":    numericScheme(Float, Float),
+	"float": numericScheme(Int, Float),
+
+	"len": func(in *inferrer) *scheme {
+		a := in.fresh()
+		return &scheme{vars: []*Var{a}, t: &Func{Parameters: []Type{&Array{Element: a}}, Return: Int}}
+	},
+	"first": func(in *inferrer) *scheme {
+		a := in.fresh()
+		return &scheme{vars: []*Var{a}, t: &Func{Parameters: []Type{&Array{Element: a}}, Return: a}}
+	},
+	"tail": func(in *inferrer) *scheme {
+		a := in.fresh()
+		return &scheme{vars: []*Var{a}, t: &Func{Parameters: []Type{&Array{Element: a}}, Return: &Array{Element: a}}}
+	},
+}
+
+// numericScheme is the type of a numeric builtin with the given parameter
+// types followed by its return type.
+func numericScheme(types ...Type) func(*inferrer) *scheme {
+	t := &Func{Parameters: types[:len(types)-1], Return: types[len(types)-1]}
+	return func(*inferrer) *scheme { return &scheme{t: t} }
+}
+
+// iterable returns the type of the sequence passed as the first of args
+// along with the type of its values: ints for ranges, strings for strings,
+// elements for arrays and anything for sequences of unknown type.
+func (in *inferrer) iterable(args []Type) (Type, Type) {
+	if len(args) > 0 && unresolved(args[0]) {
+		return in.fresh(), in.fresh()
+	}
+	if len(args) > 0 && prune(args[0]) == Range {
+		return Range, Int
+	}
+	if len(args) > 0 && prune(args[0]) == Str {
+		return Str, Str
+	}
+	a := in.fresh()
+	return &Array{Element: a}, a
+}
+
+// extreme is the type of min and max, which take either the values to
+// compare or a single sequence of them.
+func (in *inferrer) extreme(args []Type) Type {
+	if len(args) == 1 {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq}, Return: a}
+	}
+	a := in.fresh()
+	params := []Type{}
+	for range args {
+		params = append(params, a)
+	}
+	return &Func{Parameters: params, Return: a}
+}
+
+// separator is the type of the second argument of split and replace, which
+// is a regex or, by default, a string.
+func separator(args []Type) Type {
+	if len(args) > 1 && prune(args[1]) == Regex {
+		return Regex
+	}
+	return Str
+}
+
+// withLayout is the type of a time builtin taking a value and an optional
+// layout.
+func withLayout(args []Type, value Type, ret Type) Type {
+	if len(args) == 2 {
+		return &Func{Parameters: []Type{value, Str}, Return: ret}
+	}
+	return &Func{Parameters: []Type{value}, Return: ret}
+}
+
+// format is the type of sprintf and printf, which take a format string
+// followed by values of any type.
+func (in *inferrer) format(args []Type, ret Type) Type {
+	params := []Type{Str}
+	for i := 1; i < len(args); i++ {
+		params = append(params, in.fresh())
+	}
+	return &Func{Parameters: params, Return: ret}
+}
+
+func predicate(t Type) *Func {
+	return &Func{Parameters: []Type{t}, Return: Bool}
+}
+
+// realArgument is the type of the argument of a function computed in
+// floating point, which also accepts integers.
+func realArgument(args []Type) Type {
+	if len(args) == 1 && prune(args[0]) == Int {
+		return Int
+	}
+	return Float
+}
+
+func init() {
+	for name, sig := range librarySignatures {
+		sig := sig
+		if _, ok := builtinSchemes[name]; !ok {
+			builtinSchemes[name] = func(*inferrer) *scheme { return &scheme{t: sig} }
+		}
+	}
+	for name, t := range constantTypes {
+		t := t
+		builtinSchemes[name] = func(*inferrer) *scheme { return &scheme{t: t} }
+	}
+
+	for _, name := range []string{"floor", "ceil", "round"} {
+		builtinCalls[name] = func(in *inferrer, args []Type) Type {
+			return &Func{Parameters: []Type{realArgument(args)}, Return: Int}
+		}
+	}
+	for _, name := range []string{"sin", "cos", "tan", "asin", "acos", "atan", "ln", "float"} {
+		builtinCalls[name] = func(in *inferrer, args []Type) Type {
+			return &Func{Parameters: []Type{realArgument(args)}, Return: Float}
+		}
+	}
+}
+
+// builtinCalls give the type of a builtin at a call site with the given
+// argument types, which allows overloading on strings and variadic arguments.
+var builtinCalls = map[string]func(in *inferrer, args []Type) Type{
+	"len": func(in *inferrer, args []Type) Type {
+		if len(args) == 1 && (prune(args[0]) == Range || prune(args[0]) == Map) {
+			return &Func{Parameters: []Type{prune(args[0])}, Return: Int}
+		}
+		if len(args) == 1 && (prune(args[0]) == Str || unresolved(args[0])) {
+			return &Func{Parameters: []Type{args[0]}, Return: Int}
+		}
+		return in.instantiate(builtinSchemes["len"](in))
+	},
+	"first": func(in *inferrer, args []Type) Type {
+		if len(args) == 1 && prune(args[0]) == Range {
+			return &Func{Parameters: []Type{Range}, Return: Int}
+		}
+		if len(args) == 1 && prune(args[0]) == Str {
+			return &Func{Parameters: []Type{Str}, Return: Str}
+		}
+		if len(args) == 1 && unresolved(args[0]) {
+			return &Func{Parameters: []Type{args[0]}, Return: in.fresh()}
+		}
+		return in.instantiate(builtinSchemes["first"](in))
+	},
+	"tail": func(in *inferrer, args []Type) Type {
+		if len(args) == 1 && prune(args[0]) == Range {
+			return &Func{Parameters: []Type{Range}, Return: Range}
+		}
+		if len(args) == 1 && (prune(args[0]) == Str || unresolved(args[0])) {
+			// the rest of a sequence is of the same type
+			return &Func{Parameters: []Type{args[0]}, Return: args[0]}
+		}
+		return in.instantiate(builtinSchemes["tail"](in))
+	},
+	"append": func(in *inferrer, args []Type) Type {
+		a := in.fresh()
+		params := []Type{&Array{Element: a}}
+		for i := 1; i < len(args); i++ {
+			params = append(params, a)
+		}
+		return &Func{Parameters: params, Return: &Array{Element: a}}
+	},
+	"map": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		b := in.fresh()
+		return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{a}, Return: b}}, Return: &Array{Element: b}}
+	},
+	"filter": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, predicate(a)}, Return: &Array{Element: a}}
+	},
+	"reduce": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		if len(args) == 3 {
+			b := in.fresh()
+			return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{b, a}, Return: b}, b}, Return: b}
+		}
+		return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{a, a}, Return: a}}, Return: a}
+	},
+	"each": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{a}, Return: in.fresh()}}, Return: Void}
+	},
+	"any": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, predicate(a)}, Return: Bool}
+	},
+	"all": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, predicate(a)}, Return: Bool}
+	},
+	"find": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, predicate(a)}, Return: a}
+	},
+	"reverse": func(in *inferrer, args []Type) Type {
+		if len(args) == 1 && prune(args[0]) == Str {
+			return &Func{Parameters: []Type{Str}, Return: Str}
+		}
+		if len(args) == 1 && unresolved(args[0]) {
+			// strings, or arrays of anything else
+			return &Func{Parameters: []Type{args[0]}, Return: in.fresh()}
+		}
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq}, Return: &Array{Element: a}}
+	},
+	"choice": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq}, Return: a}
+	},
+	"shuffle": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq}, Return: &Array{Element: a}}
+	},
+	"sort": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		if len(args) == 2 {
+			return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{a, a}, Return: Bool}}, Return: &Array{Element: a}}
+		}
+		return &Func{Parameters: []Type{seq}, Return: &Array{Element: a}}
+	},
+	"sort_by": func(in *inferrer, args []Type) Type {
+		seq, a := in.iterable(args)
+		return &Func{Parameters: []Type{seq, &Func{Parameters: []Type{a}, Return: in.fresh()}}, Return: &Array{Element: a}}
+	},
+	"values": func(in *inferrer, args []Type) Type {
+		return &Func{Parameters: []Type{Map}, Return: &Array{Element: in.fresh()}}
+	},
+	"min": func(in *inferrer, args []Type) Type {
+		return in.extreme(args)
+	},
+	"abs": func(in *inferrer, args []Type) Type {
+		n := numeric(args...)
+		return &Func{Parameters: []Type{n}, Return: n}
+	},
+	"sqrt": func(in *inferrer, args []Type) Type {
+		n := numeric(args...)
+		return &Func{Parameters: []Type{n}, Return: n}
+	},
+	"pow": func(in *inferrer, args []Type) Type {
+		n := numeric(args...)
+		return &Func{Parameters: []Type{n, n}, Return: n}
+	},
+	"max": func(in *inferrer, args []Type) Type {
+		return in.extreme(args)
+	},
+	"split": func(in *inferrer, args []Type) Type {
+		return &Func{Parameters: []Type{Str, separator(args)}, Return: &Array{Element: Str}}
+	},
+	"replace": func(in *inferrer, args []Type) Type {
+		return &Func{Parameters: []Type{Str, separator(args), Str}, Return: Str}
+	},
+	"format_time": func(in *inferrer, args []Type) Type {
+		return withLayout(args, Int, Str)
+	},
+	"parse_time": func(in *inferrer, args []Type) Type {
+		return withLayout(args, Str, Int)
+	},
+	"sprintf": func(in *inferrer, args []Type) Type {
+		return in.format(args, Str)
+	},
+	"printf": func(in *inferrer, args []Type) Type {
+		return in.format(args, Void)
+	},
+	"print": func(in *inferrer, args []Type) Type {
+		params := []Type{}
+		for range args {
+			params = append(params, in.fresh())
+		}
+		return &Func{Parameters: params, Return: Void}
+	},
+}
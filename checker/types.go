@@ -0,0 +1,145 @@
+package checker
+
+import (
+	"strings"
+
+	"baboon/ast"
+)
+
+// Type is a static type. A nil Type is unknown and compatible with anything,
+// which is how unannotated code is checked.
+type Type interface {
+	String() string
+}
+
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+type Array struct {
+	Element Type
+}
+
+func (a *Array) String() string { return "[" + typeString(a.Element) + "]" }
+
+type Func struct {
+	Parameters []Type
+	Return     Type
+}
+
+func (f *Func) String() string {
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, typeString(p))
+	}
+	return "fn(" + strings.Join(params, ", ") + "): " + typeString(f.Return)
+}
+
+// Enum is the type of the values of a declared enum. Variants are listed in
+// declaration order along with their number of fields, -1 for variants
+// without fields.
+type Enum struct {
+	Name     string
+	Variants []string
+	Fields   map[string]int
+}
+
+func (e *Enum) String() string { return e.Name }
+
+var (
+	Int   = &Basic{Name: "int"}
+	Float = &Basic{Name: "float"}
+	Str   = &Basic{Name: "str"}
+	Bool  = &Basic{Name: "bool"}
+	Void  = &Basic{Name: "void"}
+	Range = &Basic{Name: "range"}
+	Map   = &Basic{Name: "map"}
+	Regex = &Basic{Name: "regex"}
+)
+
+// basicTypes are the names usable in annotations. any is spelled out as an
+// explicitly unknown type.
+var basicTypes = map[string]Type{
+	"int":     Int,
+	"float":   Float,
+	"str":     Str,
+	"bool":    Bool,
+	"void":    Void,
+	"range":   Range,
+	"map":     Map,
+	"regex":   Regex,
+	"any":     nil,
+	"chan":    &Basic{Name: "chan"},
+	"task":    &Basic{Name: "task"},
+	"gen":     &Basic{Name: "gen"},
+	"quote":   &Basic{Name: "quote"},
+	"builtin": &Basic{Name: "builtin"},
+}
+
+func typeString(t Type) string {
+	if t == nil {
+		return "any"
+	}
+	return t.String()
+}
+
+// assignable reports whether a value of type got may be used where want is
+// expected. Unknown types are assignable both ways.
+func assignable(want Type, got Type) bool {
+	if want == nil || got == nil {
+		return true
+	}
+
+	switch want := want.(type) {
+	case *Basic:
+		g, ok := got.(*Basic)
+		return ok && g.Name == want.Name
+	case *Enum:
+		g, ok := got.(*Enum)
+		return ok && g.Name == want.Name
+	case *Array:
+		g, ok := got.(*Array)
+		return ok && assignable(want.Element, g.Element)
+	case *Func:
+		g, ok := got.(*Func)
+		if !ok || len(g.Parameters) != len(want.Parameters) {
+			return false
+		}
+		for i := range want.Parameters {
+			if !assignable(g.Parameters[i], want.Parameters[i]) {
+				return false
+			}
+		}
+		return assignable(want.Return, g.Return)
+	default:
+		return false
+	}
+}
+
+func (c *checker) resolve(te *ast.TypeExpression) Type {
+	if te == nil {
+		return nil
+	}
+
+	switch {
+	case te.Element != nil:
+		return &Array{Element: c.resolve(te.Element)}
+	case te.Name == "fn":
+		params := []Type{}
+		for _, p := range te.Parameters {
+			params = append(params, c.resolve(p))
+		}
+		return &Func{Parameters: params, Return: c.resolve(te.Return)}
+	default:
+		if enum, ok := c.enums[te.Name]; ok {
+			return enum
+		}
+		t, ok := basicTypes[te.Name]
+		if !ok {
+			c.errorf(te.Token.Line, te.Token.Column, "unknown type: %s", te.Name)
+		}
+		return t
+	}
+}
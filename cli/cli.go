@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"baboon/checker"
 	"baboon/evaluator"
 	"baboon/lexer"
 	"baboon/object"
@@ -15,21 +16,30 @@ type opts struct {
 	name  string
 	file  string
 	str   string
+	root  string
 	eval  bool
 	parse bool
 	lex   bool
+	check bool
+	infer bool
 }
 
 func (o *opts) printHelp(code int) {
 	if code != 0 {
 		fmt.Fprintln(os.Stderr, "incorrect usage")
 	}
-	fmt.Printf("%s [-e | -p | -l] [-s <PROGRAM> | <FILE>]\n", o.name)
+	fmt.Printf("%s [-e | -p | -l] [-r <DIR>] [-s <PROGRAM> | <FILE>]\n", o.name)
+	fmt.Printf("%s check [-i] [-s <PROGRAM> | <FILE>]\n", o.name)
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	fmt.Println("\tcheck: type check input program without running it")
+	fmt.Println("\t       -i: also infer types of unannotated code")
 	fmt.Println()
 	fmt.Println("FLAGS:")
 	fmt.Println("\t-e: evaluate input program and print result")
 	fmt.Println("\t-p: parse input program and print prettified AST")
 	fmt.Println("\t-l: lex input program and print tokens")
+	fmt.Println("\t-r <DIR>: allow the program to access files in DIR")
 	fmt.Println()
 	fmt.Println("ARGUMENTS:")
 	fmt.Println("\t-s <PROGRAM>:\tread program from argument")
@@ -52,6 +62,20 @@ func parseArgs(args []string) *opts {
 			opts.parse = true
 		case "-l":
 			opts.lex = true
+		case "-i":
+			opts.infer = true
+		case "check":
+			if i != 1 {
+				opts.file = arg
+				continue
+			}
+			opts.check = true
+		case "-r":
+			if i+1 >= argc {
+				opts.printHelp(1)
+			}
+			opts.root = args[i+1]
+			i++
 		case "-s":
 			if i+1 >= argc || args[i+1][0] == '-' {
 				opts.printHelp(1)
@@ -86,7 +110,20 @@ func Run(args []string) {
 	}
 
 	l := lexer.New(input)
-	if opts.lex {
+	if opts.check {
+		p := parser.New(l)
+		prog := p.ParseProgram()
+		errors := append(p.Errors(), checker.Check(prog)...)
+		if opts.infer {
+			if err := checker.Infer(prog); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+		printErrors(errors)
+		if len(errors) > 0 {
+			os.Exit(1)
+		}
+	} else if opts.lex {
 		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
 			fmt.Printf("[%d:%d]\t%8s: %q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
 		}
@@ -99,8 +136,14 @@ func Run(args []string) {
 		p := parser.New(l)
 		prog := p.ParseProgram()
 		env := object.NewEnvironment()
+		env.Runtime().Root = opts.root
 		printErrors(p.Errors())
-		fmt.Println(evaluator.Eval(prog, env).Inspect())
+		macroEnv := object.NewEnvironment()
+		evaluator.DefineMacros(prog, macroEnv)
+		expanded, errors := evaluator.ExpandMacros(prog, macroEnv)
+		printErrors(errors)
+		fmt.Println(evaluator.Eval(expanded, env).Inspect())
+		env.Runtime().Close()
 	}
 }
 
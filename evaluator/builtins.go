@@ -3,110 +3,548 @@ package evaluator
 import (
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"baboon/object"
 	"baboon/token"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(token *token.Token, args ...object.Object) object.Object {
+	"append": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError(token, fmt.Sprintf("not enough arguments for append: expected 2, found %d", len(args)))
+			}
+
+			items := args[1:]
+			switch arr := args[0].(type) {
+			case *object.ArrayLiteral:
+				// copy so that arrays never share a backing slice
+				return &object.ArrayLiteral{Items: append(arr.Elements(), items...)}
+			default:
+				return newError(token, fmt.Sprintf("invalid argument: append(%s, ...items)", arr.Type()))
+			}
+		},
+	},
+
+	"first": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
 			if len(args) == 0 {
-				return newError(token, "not enough arguments for len: expected 1, found 0")
+				return newError(token, "not enough arguments for first: expected 1, found 0")
 			}
 			if len(args) > 1 {
-				return newError(token, fmt.Sprintf("too many arguments for len: expected 1, found %d", len(args)))
+				return newError(token, fmt.Sprintf("too many arguments for first: expected 1, found %d", len(args)))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				r, size := utf8.DecodeRuneInString(arg.Value)
+				if size == 0 {
+					return newError(token, "invalid argument for first: index 0 out of bounds")
+				}
+				return &object.String{Value: string(r)}
 			case *object.ArrayLiteral:
-				// TODO: use length field
-				return &object.Integer{Value: int64(len(arg.Items))}
+				if arg.Len() == 0 {
+					return newError(token, "invalid argument for first: index 0 out of bounds")
+				}
+				return arg.Get(0)
+			case *object.Range:
+				n, ok := arg.Index(0)
+				if !ok {
+					return newError(token, "invalid argument for first: index 0 out of bounds")
+				}
+				return &object.Integer{Value: n}
+			case object.Iterator:
+				item, ok := arg.Next()
+				if !ok {
+					return newError(token, "invalid argument for first: iterator exhausted")
+				}
+				return item
+			default:
+				return newError(token, fmt.Sprintf("invalid argument: first(%s)", arg.Type()))
+			}
+		},
+	},
+
+	"tail": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, "not enough arguments for tail: expected 1, found 0")
+			}
+			if len(args) > 1 {
+				return newError(token, fmt.Sprintf("too many arguments for tail: expected 1, found %d", len(args)))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				_, size := utf8.DecodeRuneInString(arg.Value)
+				return &object.String{Value: arg.Value[size:]}
+			case *object.ArrayLiteral:
+				if arg.Len() == 0 {
+					return &object.ArrayLiteral{Items: []object.Object{}}
+				}
+				return &object.ArrayLiteral{Items: arg.Elements()[1:]}
+			case *object.Range:
+				if _, ok := arg.Index(0); !ok {
+					return arg
+				}
+				start, ok := arg.Index(1)
+				if !ok {
+					// r has a single element, and the next one may overflow
+					return &object.Range{Start: arg.End, End: arg.End, Step: arg.Step}
+				}
+				return &object.Range{Start: start, End: arg.End, Step: arg.Step, Inclusive: arg.Inclusive}
 			default:
-				return newError(token, fmt.Sprintf("invalid argument: len(%s)", arg.Type()))
+				return newError(token, fmt.Sprintf("invalid argument: tail(%s)", arg.Type()))
+			}
+		},
+	},
+
+	"next": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, "not enough arguments for next: expected 1, found 0")
+			}
+			if len(args) > 2 {
+				return newError(token, fmt.Sprintf("too many arguments for next: expected 2, found %d", len(args)))
+			}
+
+			it, ok := args[0].(object.Iterator)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: next(%s)", args[0].Type()))
+			}
+
+			item, ok := it.Next()
+			if ok {
+				return item
+			}
+			if len(args) == 2 {
+				return args[1]
 			}
+			return newError(token, "invalid argument for next: iterator exhausted")
 		},
 	},
 
-	"print": {
-		Fn: func(_ *token.Token, args ...object.Object) object.Object {
-			out := []string{}
-			for _, arg := range args {
-				out = append(out, arg.Inspect())
+	"keys": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for keys: expected 1, found %d", len(args)))
+			}
+			m, ok := args[0].(*object.Map)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: keys(%s)", args[0].Type()))
+			}
+
+			items := []object.Object{}
+			for _, key := range m.Keys() {
+				items = append(items, &object.String{Value: key})
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"values": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for values: expected 1, found %d", len(args)))
+			}
+			m, ok := args[0].(*object.Map)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: values(%s)", args[0].Type()))
+			}
+
+			items := []object.Object{}
+			for _, key := range m.Keys() {
+				val, _ := m.Get(key)
+				items = append(items, val)
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"instanceof": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for instanceof: expected 2, found %d", len(args)))
+			}
+
+			switch class := args[1].(type) {
+			case *object.Class:
+				instance, ok := args[0].(*object.Instance)
+				return newBoolean(ok && instance.Class.IsA(class))
+			case *object.Enum:
+				variant, ok := args[0].(*object.Variant)
+				return newBoolean(ok && variant.Constructor.Enum == class)
+			default:
+				return newError(token, fmt.Sprintf("invalid argument: instanceof(%s, %s)", args[0].Type(), args[1].Type()))
+			}
+		},
+	},
+
+	"type": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for type: expected 2, found %d", len(args)))
+			}
+
+			name, ok := args[0].(*object.String)
+			methods, isArray := args[1].(*object.ArrayLiteral)
+			if !ok || !isArray {
+				return newError(token, fmt.Sprintf("invalid argument: type(%s, %s)", args[0].Type(), args[1].Type()))
+			}
+
+			ut := &object.UserType{Name: name.Value, Methods: map[string]*object.Function{}}
+			for _, item := range methods.Elements() {
+				// methods are named functions or [name, function] pairs
+				var methodName string
+				var method *object.Function
+				switch item := item.(type) {
+				case *object.Function:
+					methodName, method = item.Name, item
+				case *object.ArrayLiteral:
+					if pair := item.Elements(); len(pair) == 2 {
+						n, _ := pair[0].(*object.String)
+						fn, _ := pair[1].(*object.Function)
+						if n != nil && fn != nil {
+							methodName, method = n.Value, fn
+						}
+					}
+				}
+				if methodName == "" {
+					return newError(token, fmt.Sprintf("invalid method for %s: %s", name.Value, item.Inspect()))
+				}
+				ut.Methods[methodName] = method
+			}
+			return ut
+		},
+	},
+
+	"channel": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError(token, fmt.Sprintf("too many arguments for channel: expected 1, found %d", len(args)))
+			}
+
+			capacity := int64(0)
+			if len(args) == 1 {
+				arg, ok := args[0].(*object.Integer)
+				if !ok || arg.Value < 0 {
+					return newError(token, fmt.Sprintf("invalid argument: channel(%s)", args[0].Inspect()))
+				}
+				capacity = arg.Value
+			}
+
+			return env.Runtime().Scheduler.NewChannel(int(capacity))
+		},
+	},
+
+	"send": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for send: expected 2, found %d", len(args)))
+			}
+
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: send(%s, value)", args[0].Type()))
+			}
+			if err := ch.Send(args[1]); err != nil {
+				return newError(token, err.Error())
 			}
-			fmt.Println(strings.Join(out, " "))
-			// TODO: add void?
 			return VOID
 		},
 	},
 
-	"append": {
-		Fn: func(token *token.Token, args ...object.Object) object.Object {
-			if len(args) < 2 {
-				return newError(token, fmt.Sprintf("not enough arguments for append: expected 2, found %d", len(args)))
+	"recv": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, "not enough arguments for recv: expected 1, found 0")
+			}
+			if len(args) > 2 {
+				return newError(token, fmt.Sprintf("too many arguments for recv: expected 2, found %d", len(args)))
 			}
 
-			items := args[1:]
-			switch arr := args[0].(type) {
-			case *object.ArrayLiteral:
-				return &object.ArrayLiteral{Items: append(arr.Items, items...)}
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: recv(%s)", args[0].Type()))
+			}
+
+			val, ok, err := ch.Recv()
+			switch {
+			case err != nil:
+				return newError(token, err.Error())
+			case ok:
+				return val
+			case len(args) == 2:
+				return args[1]
 			default:
-				return newError(token, fmt.Sprintf("invalid argument: append(%s, ...items)", arr.Type()))
+				return newError(token, "invalid argument for recv: channel is closed")
 			}
 		},
 	},
 
-	"first": {
-		Fn: func(token *token.Token, args ...object.Object) object.Object {
+	"close": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for close: expected 1, found %d", len(args)))
+			}
+
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: close(%s)", args[0].Type()))
+			}
+			if err := ch.Close(); err != nil {
+				return newError(token, err.Error())
+			}
+			return VOID
+		},
+	},
+
+	"wait": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for wait: expected 1, found %d", len(args)))
+			}
+
+			task, ok := args[0].(*object.Task)
+			if !ok {
+				return newError(token, fmt.Sprintf("invalid argument: wait(%s)", args[0].Type()))
+			}
+
+			result, err := task.Wait()
+			if err != nil {
+				return newError(token, err.Error())
+			}
+			return result
+		},
+	},
+
+	"freeze": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
 			if len(args) == 0 {
-				return newError(token, "not enough arguments for first: expected 1, found 0")
+				return newError(token, "not enough arguments for freeze: expected 1, found 0")
 			}
 			if len(args) > 1 {
-				return newError(token, fmt.Sprintf("too many arguments for first: expected 1, found %d", len(args)))
+				return newError(token, fmt.Sprintf("too many arguments for freeze: expected 1, found %d", len(args)))
+			}
+
+			return object.Freeze(args[0])
+		},
+	},
+
+	"bytes": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, "not enough arguments for bytes: expected 1, found 0")
+			}
+			if len(args) > 1 {
+				return newError(token, fmt.Sprintf("too many arguments for bytes: expected 1, found %d", len(args)))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				if len(arg.Value) == 0 {
-					return newError(token, "invalid argument for first: index 0 out of bounds")
+				items := make([]object.Object, len(arg.Value))
+				for i := 0; i < len(arg.Value); i++ {
+					items[i] = &object.Integer{Value: int64(arg.Value[i])}
 				}
-				return &object.String{Value: string(arg.Value[0])}
+				return &object.ArrayLiteral{Items: items}
 			case *object.ArrayLiteral:
-				if len(arg.Items) == 0 {
-					return newError(token, "invalid argument for first: index 0 out of bounds")
+				items := arg.Elements()
+				buf := make([]byte, len(items))
+				for i, item := range items {
+					b, ok := item.(*object.Integer)
+					if !ok || b.Value < 0 || b.Value > 255 {
+						return newError(token, fmt.Sprintf("invalid argument for bytes: item %d is not a byte", i))
+					}
+					buf[i] = byte(b.Value)
 				}
-				return arg.Items[0]
+				return &object.String{Value: string(buf)}
 			default:
-				return newError(token, fmt.Sprintf("invalid argument: first(%s)", arg.Type()))
+				return newError(token, fmt.Sprintf("invalid argument: bytes(%s)", arg.Type()))
 			}
 		},
 	},
 
-	"tail": {
-		Fn: func(token *token.Token, args ...object.Object) object.Object {
+	"runes": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
 			if len(args) == 0 {
-				return newError(token, "not enough arguments for tail: expected 1, found 0")
+				return newError(token, "not enough arguments for runes: expected 1, found 0")
 			}
 			if len(args) > 1 {
-				return newError(token, fmt.Sprintf("too many arguments for tail: expected 1, found %d", len(args)))
+				return newError(token, fmt.Sprintf("too many arguments for runes: expected 1, found %d", len(args)))
 			}
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				if len(arg.Value) == 0 {
-					return arg
+				items := []object.Object{}
+				for _, r := range arg.Value {
+					items = append(items, &object.Integer{Value: int64(r)})
 				}
-				return &object.String{Value: arg.Value[1:]}
+				return &object.ArrayLiteral{Items: items}
 			case *object.ArrayLiteral:
-				if len(arg.Items) == 0 {
-					return arg
+				items := arg.Elements()
+				runes := make([]rune, len(items))
+				for i, item := range items {
+					r, ok := item.(*object.Integer)
+					if !ok || r.Value < 0 || r.Value > unicode.MaxRune {
+						return newError(token, fmt.Sprintf("invalid argument for runes: item %d is not a code point", i))
+					}
+					runes[i] = rune(r.Value)
 				}
-				return &object.ArrayLiteral{Items: arg.Items[1:]}
+				return &object.String{Value: string(runes)}
 			default:
-				return newError(token, fmt.Sprintf("invalid argument: tail(%s)", arg.Type()))
+				return newError(token, fmt.Sprintf("invalid argument: runes(%s)", arg.Type()))
+			}
+		},
+	},
+
+	"graphemes": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, "not enough arguments for graphemes: expected 1, found 0")
+			}
+			if len(args) > 1 {
+				return newError(token, fmt.Sprintf("too many arguments for graphemes: expected 1, found %d", len(args)))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.String:
+				items := []object.Object{}
+				for _, g := range splitGraphemes(arg.Value) {
+					items = append(items, &object.String{Value: g})
+				}
+				return &object.ArrayLiteral{Items: items}
+			default:
+				return newError(token, fmt.Sprintf("invalid argument: graphemes(%s)", arg.Type()))
 			}
 		},
 	},
 }
+
+// builtins calling back into the evaluator are registered in init, as
+// referring to them from the builtins map would be an initialization cycle.
+func init() {
+	builtins["len"] = &object.Builtin{Fn: builtinLen}
+	builtins["print"] = &object.Builtin{Fn: builtinPrint}
+	builtins["array"] = &object.Builtin{Fn: builtinArray}
+}
+
+func builtinLen(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(token, "not enough arguments for len: expected 1, found 0")
+	}
+	if len(args) > 1 {
+		return newError(token, fmt.Sprintf("too many arguments for len: expected 1, found %d", len(args)))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.ArrayLiteral:
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Range:
+		n, ok := arg.Len()
+		if !ok {
+			return newError(token, fmt.Sprintf("integer overflow: len(%s)", arg.Inspect()))
+		}
+		return &object.Integer{Value: n}
+	case *object.Map:
+		return &object.Integer{Value: int64(arg.Len())}
+	default:
+		if res, ok := callProtocol(arg, "__len", nil, token); ok {
+			return protocolResult("__len", res, object.INTEGER_OBJ, token)
+		}
+		return newError(token, fmt.Sprintf("invalid argument: len(%s)", arg.Type()))
+	}
+}
+
+func builtinPrint(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+	out := []string{}
+	for _, arg := range args {
+		str := display(arg, token)
+		if str.Type() == object.ERROR_OBJ {
+			return str
+		}
+		out = append(out, str.(*object.String).Value)
+	}
+	fmt.Fprintln(env.Runtime().Stdout, strings.Join(out, " "))
+	// TODO: add void?
+	return VOID
+}
+
+func builtinArray(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(token, "not enough arguments for array: expected 1, found 0")
+	}
+	if len(args) > 1 {
+		return newError(token, fmt.Sprintf("too many arguments for array: expected 1, found %d", len(args)))
+	}
+
+	it, ok := iterate(args[0], token)
+	if !ok {
+		return newError(token, fmt.Sprintf("invalid argument: array(%s)", args[0].Type()))
+	}
+
+	items := []object.Object{}
+	for item, ok := it.Next(); ok; item, ok = it.Next() {
+		if item.Type() == object.ERROR_OBJ {
+			return item
+		}
+		items = append(items, item)
+	}
+	return &object.ArrayLiteral{Items: items}
+}
+
+// splitGraphemes approximates extended grapheme clusters: combining marks,
+// variation selectors, emoji modifiers and ZWJ sequences stay attached to the
+// preceding code point, regional indicators pair up into flags and CRLF is
+// kept together.
+func splitGraphemes(s string) []string {
+	clusters := []string{}
+	start := 0
+	var prev rune = -1
+	regional := 0
+
+	for i, r := range s {
+		if prev != -1 && !extendsGrapheme(prev, r, regional) {
+			clusters = append(clusters, s[start:i])
+			start = i
+			regional = 0
+		}
+		if isRegionalIndicator(r) {
+			regional++
+		}
+		prev = r
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+
+	return clusters
+}
+
+func extendsGrapheme(prev rune, r rune, regional int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == '\u200d':
+		return true
+	case r == '\u200d':
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case unicode.Is(unicode.Variation_Selector, r):
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji skin tone modifiers
+		return true
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		return regional%2 == 1
+	default:
+		return false
+	}
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
@@ -0,0 +1,449 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range arrayBuiltins {
+		builtins[name] = b
+	}
+}
+
+// each calls fn with every value of the iterable obj until fn returns false
+// or an error, which is then returned. Stopping early closes the iterator.
+func each(name string, obj object.Object, token *token.Token, fn func(item object.Object) (bool, object.Object)) object.Object {
+	it, ok := iterate(obj, token)
+	if !ok {
+		return newError(token, fmt.Sprintf("invalid argument for %s: cannot iterate over %s", name, obj.Type()))
+	}
+
+	for item, ok := it.Next(); ok; item, ok = it.Next() {
+		if item.Type() == object.ERROR_OBJ {
+			return item
+		}
+		more, err := fn(item)
+		if err != nil {
+			object.Stop(it)
+			return err
+		}
+		if !more {
+			object.Stop(it)
+			break
+		}
+	}
+	return nil
+}
+
+// callPredicate applies fn to args and checks that it returns a boolean.
+func callPredicate(name string, fn object.Object, args []object.Object, env *object.Environment, token *token.Token) (bool, object.Object) {
+	res := applyFunction(fn, args, env, token)
+	if res.Type() == object.ERROR_OBJ {
+		return false, res
+	}
+	if res != TRUE && res != FALSE {
+		return false, newError(token, fmt.Sprintf("invalid result for %s: expected BOOLEAN, found %s", name, res.Type()))
+	}
+	return res == TRUE, nil
+}
+
+// compare orders integers, floats and strings, which are the values sorted without
+// a comparator.
+func compare(a object.Object, b object.Object) (int, bool) {
+	switch a := a.(type) {
+	case *object.Integer:
+		if b, ok := b.(*object.Integer); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, true
+			case a.Value > b.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+	case *object.Float:
+		if b, ok := b.(*object.Float); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, true
+			case a.Value > b.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+	case *object.String:
+		if b, ok := b.(*object.String); ok {
+			switch {
+			case a.Value < b.Value:
+				return -1, true
+			case a.Value > b.Value:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// sortStable sorts items in place by less, stopping at the first error.
+func sortStable(items []object.Object, less func(a object.Object, b object.Object) (bool, object.Object)) object.Object {
+	var err object.Object
+	sort.SliceStable(items, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		res, e := less(items[i], items[j])
+		if e != nil {
+			err = e
+		}
+		return res
+	})
+	return err
+}
+
+// naturalLess orders values by compare, for sort and sort_by.
+func naturalLess(name string, token *token.Token) func(a object.Object, b object.Object) (bool, object.Object) {
+	return func(a object.Object, b object.Object) (bool, object.Object) {
+		c, ok := compare(a, b)
+		if !ok {
+			return false, newError(token, fmt.Sprintf("invalid argument for %s: cannot compare %s and %s", name, a.Type(), b.Type()))
+		}
+		return c < 0, nil
+	}
+}
+
+// collect returns the values of the iterable obj in a new array.
+func collect(name string, obj object.Object, token *token.Token) ([]object.Object, object.Object) {
+	items := []object.Object{}
+	err := each(name, obj, token, func(item object.Object) (bool, object.Object) {
+		items = append(items, item)
+		return true, nil
+	})
+	return items, err
+}
+
+var arrayBuiltins = map[string]*object.Builtin{
+	"map": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for map: expected 2, found %d", len(args)))
+			}
+
+			items := []object.Object{}
+			err := each("map", args[0], token, func(item object.Object) (bool, object.Object) {
+				res := applyFunction(args[1], []object.Object{item}, env, token)
+				if res.Type() == object.ERROR_OBJ {
+					return false, res
+				}
+				items = append(items, res)
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"filter": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for filter: expected 2, found %d", len(args)))
+			}
+
+			items := []object.Object{}
+			err := each("filter", args[0], token, func(item object.Object) (bool, object.Object) {
+				keep, err := callPredicate("filter", args[1], []object.Object{item}, env, token)
+				if keep {
+					items = append(items, item)
+				}
+				return err == nil, err
+			})
+			if err != nil {
+				return err
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"reduce": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) < 2 || len(args) > 3 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for reduce: expected 2 or 3, found %d", len(args)))
+			}
+
+			// without an initial value the first item is used
+			var acc object.Object
+			if len(args) == 3 {
+				acc = args[2]
+			}
+			err := each("reduce", args[0], token, func(item object.Object) (bool, object.Object) {
+				if acc == nil {
+					acc = item
+					return true, nil
+				}
+				acc = applyFunction(args[1], []object.Object{acc, item}, env, token)
+				if acc.Type() == object.ERROR_OBJ {
+					return false, acc
+				}
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			if acc == nil {
+				return newError(token, "invalid argument for reduce: empty sequence and no initial value")
+			}
+			return acc
+		},
+	},
+
+	"each": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for each: expected 2, found %d", len(args)))
+			}
+
+			err := each("each", args[0], token, func(item object.Object) (bool, object.Object) {
+				res := applyFunction(args[1], []object.Object{item}, env, token)
+				if res.Type() == object.ERROR_OBJ {
+					return false, res
+				}
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return VOID
+		},
+	},
+
+	"any": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for any: expected 2, found %d", len(args)))
+			}
+
+			found := false
+			err := each("any", args[0], token, func(item object.Object) (bool, object.Object) {
+				ok, err := callPredicate("any", args[1], []object.Object{item}, env, token)
+				found = ok
+				return !ok && err == nil, err
+			})
+			if err != nil {
+				return err
+			}
+			return newBoolean(found)
+		},
+	},
+
+	"all": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for all: expected 2, found %d", len(args)))
+			}
+
+			all := true
+			err := each("all", args[0], token, func(item object.Object) (bool, object.Object) {
+				ok, err := callPredicate("all", args[1], []object.Object{item}, env, token)
+				all = ok
+				return ok, err
+			})
+			if err != nil {
+				return err
+			}
+			return newBoolean(all)
+		},
+	},
+
+	"find": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for find: expected 2, found %d", len(args)))
+			}
+
+			var found object.Object = VOID
+			err := each("find", args[0], token, func(item object.Object) (bool, object.Object) {
+				ok, err := callPredicate("find", args[1], []object.Object{item}, env, token)
+				if ok {
+					found = item
+				}
+				return !ok && err == nil, err
+			})
+			if err != nil {
+				return err
+			}
+			return found
+		},
+	},
+
+	"zip": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) < 2 {
+				return newError(token, fmt.Sprintf("not enough arguments for zip: expected 2, found %d", len(args)))
+			}
+
+			iters := make([]object.Iterator, len(args))
+			for i, arg := range args {
+				it, ok := iterate(arg, token)
+				if !ok {
+					return newError(token, fmt.Sprintf("invalid argument for zip: cannot iterate over %s", arg.Type()))
+				}
+				iters[i] = it
+			}
+
+			stop := func() {
+				for _, it := range iters {
+					object.Stop(it)
+				}
+			}
+
+			// stops with the shortest argument
+			tuples := []object.Object{}
+			for {
+				tuple := make([]object.Object, len(iters))
+				for i, it := range iters {
+					item, ok := it.Next()
+					if !ok {
+						stop()
+						return &object.ArrayLiteral{Items: tuples}
+					}
+					if item.Type() == object.ERROR_OBJ {
+						stop()
+						return item
+					}
+					tuple[i] = item
+				}
+				tuples = append(tuples, &object.ArrayLiteral{Items: tuple})
+			}
+		},
+	},
+
+	"enumerate": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for enumerate: expected 1, found %d", len(args)))
+			}
+
+			pairs := []object.Object{}
+			err := each("enumerate", args[0], token, func(item object.Object) (bool, object.Object) {
+				idx := &object.Integer{Value: int64(len(pairs))}
+				pairs = append(pairs, &object.ArrayLiteral{Items: []object.Object{idx, item}})
+				return true, nil
+			})
+			if err != nil {
+				return err
+			}
+			return &object.ArrayLiteral{Items: pairs}
+		},
+	},
+
+	"flatten": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("flatten", token, args, object.ARRAY_OBJ); err != nil {
+				return err
+			}
+
+			// only one level of nesting is removed
+			items := []object.Object{}
+			for _, item := range args[0].(*object.ArrayLiteral).Elements() {
+				if arr, ok := item.(*object.ArrayLiteral); ok {
+					items = append(items, arr.Elements()...)
+				} else {
+					items = append(items, item)
+				}
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"reverse": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for reverse: expected 1, found %d", len(args)))
+			}
+
+			if s, ok := args[0].(*object.String); ok {
+				runes := []rune(s.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			}
+
+			items, err := collect("reverse", args[0], token)
+			if err != nil {
+				return err
+			}
+			for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+				items[i], items[j] = items[j], items[i]
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"sort": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for sort: expected 1 or 2, found %d", len(args)))
+			}
+
+			items, err := collect("sort", args[0], token)
+			if err != nil {
+				return err
+			}
+
+			// a comparator reports whether its first argument goes first
+			less := naturalLess("sort", token)
+			if len(args) == 2 {
+				less = func(a object.Object, b object.Object) (bool, object.Object) {
+					return callPredicate("sort", args[1], []object.Object{a, b}, env, token)
+				}
+			}
+			if err := sortStable(items, less); err != nil {
+				return err
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	"sort_by": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for sort_by: expected 2, found %d", len(args)))
+			}
+
+			items, err := collect("sort_by", args[0], token)
+			if err != nil {
+				return err
+			}
+
+			// keys are computed once and sorted along with their items
+			pairs := make([]object.Object, len(items))
+			for i, item := range items {
+				key := applyFunction(args[1], []object.Object{item}, env, token)
+				if key.Type() == object.ERROR_OBJ {
+					return key
+				}
+				pairs[i] = &object.ArrayLiteral{Items: []object.Object{key, item}}
+			}
+
+			byKey := naturalLess("sort_by", token)
+			err = sortStable(pairs, func(a object.Object, b object.Object) (bool, object.Object) {
+				return byKey(a.(*object.ArrayLiteral).Items[0], b.(*object.ArrayLiteral).Items[0])
+			})
+			if err != nil {
+				return err
+			}
+
+			for i, pair := range pairs {
+				items[i] = pair.(*object.ArrayLiteral).Items[1]
+			}
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+}
@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range fileBuiltins {
+		builtins[name] = b
+	}
+}
+
+// fileError reports err, hiding the host path of path behind the path the
+// script used.
+func fileError(name string, path string, token *token.Token, err error) *object.Error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError(token, fmt.Sprintf("%s failed: %s: %v", name, path, err))
+}
+
+// fileFunction wraps a file operation on the host path of its first
+// argument, which must be a string like the other argument types.
+func fileFunction(name string, types []object.ObjectType, fn func(path string, args []object.Object) (object.Object, error)) *object.Builtin {
+	return fileOperation(name, types, (*object.Runtime).Resolve, fn)
+}
+
+// entryFunction is fileFunction for operations on an entry of the allowed
+// directory, which must not be applied to the directory itself.
+func entryFunction(name string, types []object.ObjectType, fn func(path string, args []object.Object) (object.Object, error)) *object.Builtin {
+	return fileOperation(name, types, (*object.Runtime).ResolveEntry, fn)
+}
+
+func fileOperation(name string, types []object.ObjectType, resolve func(*object.Runtime, string) (string, error), fn func(path string, args []object.Object) (object.Object, error)) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs(name, token, args, types...); err != nil {
+				return err
+			}
+
+			path := args[0].(*object.String).Value
+			full, err := resolve(env.Runtime(), path)
+			if err != nil {
+				return fileError(name, path, token, err)
+			}
+
+			res, err := fn(full, args)
+			if err != nil {
+				return fileError(name, path, token, err)
+			}
+			return res
+		},
+	}
+}
+
+var (
+	pathArg        = []object.ObjectType{object.STRING_OBJ}
+	pathContentArg = []object.ObjectType{object.STRING_OBJ, object.STRING_OBJ}
+)
+
+var fileBuiltins = map[string]*object.Builtin{
+	"read_file": fileFunction("read_file", pathArg, func(path string, _ []object.Object) (object.Object, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: string(data)}, nil
+	}),
+
+	"write_file": fileFunction("write_file", pathContentArg, func(path string, args []object.Object) (object.Object, error) {
+		return VOID, os.WriteFile(path, []byte(args[1].(*object.String).Value), 0o644)
+	}),
+
+	"append_file": fileFunction("append_file", pathContentArg, func(path string, args []object.Object) (object.Object, error) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.WriteString(args[1].(*object.String).Value); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return VOID, f.Close()
+	}),
+
+	"exists": fileFunction("exists", pathArg, func(path string, _ []object.Object) (object.Object, error) {
+		_, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return FALSE, nil
+		}
+		return newBoolean(err == nil), err
+	}),
+
+	"list_dir": fileFunction("list_dir", pathArg, func(path string, _ []object.Object) (object.Object, error) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		// sorted by name
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return stringArray(names), nil
+	}),
+
+	"mkdir": fileFunction("mkdir", pathArg, func(path string, _ []object.Object) (object.Object, error) {
+		return VOID, os.MkdirAll(path, 0o755)
+	}),
+
+	// directories must be empty to be removed
+	"remove": entryFunction("remove", pathArg, func(path string, _ []object.Object) (object.Object, error) {
+		return VOID, os.Remove(path)
+	}),
+}
@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	builtins["sprintf"] = &object.Builtin{Fn: builtinSprintf}
+	builtins["printf"] = &object.Builtin{Fn: builtinPrintf}
+}
+
+func builtinSprintf(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+	return format("sprintf", token, args)
+}
+
+func builtinPrintf(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+	res := format("printf", token, args)
+	if res.Type() == object.ERROR_OBJ {
+		return res
+	}
+	fmt.Fprint(env.Runtime().Stdout, res.(*object.String).Value)
+	return VOID
+}
+
+// format formats its arguments according to the format string passed as the
+// first of them. Verbs are written like in Go, with flags, width and
+// precision:
+//
+//	%v, %s  display form, using __str if implemented
+//	%q      debug form, using __str if implemented
+//	%d      integer in decimal, or %x, %X, %o, %b in other bases
+//	%c      character with the integer code point
+//	%f      number in decimal notation, or %e, %E, %g, %G
+//	%%      percent sign
+func format(name string, token *token.Token, args []object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(token, fmt.Sprintf("not enough arguments for %s: expected 1, found 0", name))
+	}
+	f, ok := args[0].(*object.String)
+	if !ok {
+		return newError(token, fmt.Sprintf("invalid argument: %s(%s, ...)", name, args[0].Type()))
+	}
+	args = args[1:]
+
+	fail := func(format string, a ...interface{}) object.Object {
+		return newError(token, fmt.Sprintf("invalid argument for %s: ", name)+fmt.Sprintf(format, a...))
+	}
+
+	var out strings.Builder
+	next := 0
+	runes := []rune(f.Value)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '%' {
+			out.WriteRune(runes[i])
+			continue
+		}
+
+		// flags, width and precision are passed on to fmt
+		start := i
+		i++
+		for i < len(runes) && strings.ContainsRune("-+ 0#", runes[i]) {
+			i++
+		}
+		for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+			i++
+		}
+		if i < len(runes) && runes[i] == '.' {
+			i++
+			for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+				i++
+			}
+		}
+		if i >= len(runes) {
+			return fail("unterminated verb %s", string(runes[start:]))
+		}
+		spec, verb := string(runes[start:i]), runes[i]
+
+		if verb == '%' {
+			out.WriteRune('%')
+			continue
+		}
+		if next >= len(args) {
+			return fail("missing argument for %s%c", spec, verb)
+		}
+		arg := args[next]
+		next++
+
+		switch verb {
+		case 'v', 's':
+			str := display(arg, token)
+			if str.Type() == object.ERROR_OBJ {
+				return str
+			}
+			fmt.Fprintf(&out, spec+"s", str.(*object.String).Value)
+		case 'q':
+			str := inspect(arg, token)
+			if str.Type() == object.ERROR_OBJ {
+				return str
+			}
+			fmt.Fprintf(&out, spec+"s", str.(*object.String).Value)
+		case 'd', 'x', 'X', 'o', 'b', 'c':
+			n, ok := arg.(*object.Integer)
+			if !ok {
+				return fail("%s%c expects INTEGER, found %s", spec, verb, arg.Type())
+			}
+			if verb == 'c' {
+				fmt.Fprintf(&out, spec+"c", rune(n.Value))
+			} else {
+				fmt.Fprintf(&out, spec+string(verb), n.Value)
+			}
+		case 'f', 'e', 'E', 'g', 'G':
+			x, ok := toFloat(arg)
+			if !ok {
+				return fail("%s%c expects FLOAT, found %s", spec, verb, arg.Type())
+			}
+			fmt.Fprintf(&out, spec+string(verb), x)
+		default:
+			return fail("unknown verb %s%c", spec, verb)
+		}
+	}
+
+	if next < len(args) {
+		return fail("too many arguments for format string, expected %d, found %d", next, len(args))
+	}
+	return &object.String{Value: out.String()}
+}
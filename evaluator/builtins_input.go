@@ -0,0 +1,90 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range inputBuiltins {
+		builtins[name] = b
+	}
+}
+
+// readLine reads a line of input, which is void once the input has ended.
+func readLine(name string, env *object.Environment, token *token.Token) object.Object {
+	line, err := env.Runtime().ReadLine()
+	if err == io.EOF {
+		return VOID
+	}
+	if err != nil {
+		return newError(token, fmt.Sprintf("%s failed: %v", name, err))
+	}
+	return &object.String{Value: line}
+}
+
+var inputBuiltins = map[string]*object.Builtin{
+	"input": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newError(token, fmt.Sprintf("too many arguments for input: expected 1, found %d", len(args)))
+			}
+
+			if len(args) == 1 {
+				prompt, ok := args[0].(*object.String)
+				if !ok {
+					return invalidArguments("input", token, args)
+				}
+				fmt.Fprint(env.Runtime().Stdout, prompt.Value)
+			}
+			return readLine("input", env, token)
+		},
+	},
+
+	"read_line": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("read_line", token, args); err != nil {
+				return err
+			}
+			return readLine("read_line", env, token)
+		},
+	},
+
+	"read_all": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("read_all", token, args); err != nil {
+				return err
+			}
+
+			data, err := env.Runtime().ReadAll()
+			if err != nil {
+				return newError(token, fmt.Sprintf("read_all failed: %v", err))
+			}
+			return &object.String{Value: data}
+		},
+	},
+
+	// lines generates the remaining lines of input
+	"lines": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("lines", token, args); err != nil {
+				return err
+			}
+
+			return env.Runtime().NewGenerator(func(yield func(object.Object) bool) object.Object {
+				for {
+					line := readLine("lines", env, token)
+					if line == VOID {
+						return VOID
+					}
+					if !yield(line) || line.Type() == object.ERROR_OBJ {
+						return VOID
+					}
+				}
+			})
+		},
+	},
+}
@@ -0,0 +1,538 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range jsonBuiltins {
+		builtins[name] = b
+	}
+}
+
+var jsonBuiltins = map[string]*object.Builtin{
+	"json_parse": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("json_parse", token, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			p := &jsonParser{src: args[0].(*object.String).Value, line: 1, column: 1}
+			val, err := p.parse()
+			if err != nil {
+				return newError(token, err.Error())
+			}
+			return val
+		},
+	},
+
+	"json_stringify": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for json_stringify: expected 1 or 2, found %d", len(args)))
+			}
+
+			s := &jsonStringifier{seen: map[object.Object]bool{}}
+			if len(args) == 2 {
+				switch indent := args[1].(type) {
+				case *object.Integer:
+					if indent.Value < 0 {
+						return newError(token, fmt.Sprintf("invalid argument for json_stringify: negative indent %d", indent.Value))
+					}
+					if indent.Value > maxJSONIndent {
+						return newError(token, fmt.Sprintf("invalid argument for json_stringify: indent %d is greater than %d", indent.Value, maxJSONIndent))
+					}
+					s.indent = strings.Repeat(" ", int(indent.Value))
+				case *object.String:
+					s.indent = indent.Value
+				default:
+					return invalidArguments("json_stringify", token, args)
+				}
+				s.pretty = true
+			}
+
+			if err := s.value(args[0], 0); err != "" {
+				return newError(token, "invalid argument for json_stringify: "+err)
+			}
+			return &object.String{Value: s.out.String()}
+		},
+	},
+}
+
+// maxJSONIndent is the widest indent in spaces json_stringify accepts.
+const maxJSONIndent = 10
+
+// jsonError is a parse error at a line and column of the input, counted in
+// runes from 1.
+type jsonError struct {
+	line    int
+	column  int
+	message string
+}
+
+func (e *jsonError) Error() string {
+	return fmt.Sprintf("invalid JSON at %d:%d: %s", e.line, e.column, e.message)
+}
+
+// maxJSONDepth bounds the nesting of arrays and objects parsed, which would
+// otherwise be bounded only by the stack.
+const maxJSONDepth = 10000
+
+// jsonParser decodes JSON into objects. Objects become maps, which keep the
+// order of their keys, and null becomes void. Numbers with a fraction or an
+// exponent become floats, other numbers integers.
+type jsonParser struct {
+	src    string
+	pos    int
+	line   int
+	column int
+	// arrays and objects being parsed
+	depth int
+}
+
+func (p *jsonParser) parse() (object.Object, error) {
+	val, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.unexpected()
+	}
+	return val, nil
+}
+
+func (p *jsonParser) errorf(format string, args ...interface{}) error {
+	return &jsonError{line: p.line, column: p.column, message: fmt.Sprintf(format, args...)}
+}
+
+func (p *jsonParser) unexpected() error {
+	if p.pos >= len(p.src) {
+		return p.errorf("unexpected end of input")
+	}
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return p.errorf("unexpected character %q", r)
+}
+
+func (p *jsonParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// advance moves past the rune at the current position.
+func (p *jsonParser) advance() {
+	r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	p.pos += size
+	if r == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+}
+
+func (p *jsonParser) skipSpace() {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.advance()
+		default:
+			return
+		}
+	}
+}
+
+// literal consumes word if the input continues with it.
+func (p *jsonParser) literal(word string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], word) {
+		return false
+	}
+	for range word {
+		p.advance()
+	}
+	return true
+}
+
+func (p *jsonParser) value() (object.Object, error) {
+	p.skipSpace()
+
+	if c := p.peek(); c == '{' || c == '[' {
+		if p.depth == maxJSONDepth {
+			return nil, p.errorf("nesting too deep")
+		}
+		p.depth++
+		defer func() { p.depth-- }()
+	}
+
+	switch c := p.peek(); {
+	case c == '{':
+		return p.object()
+	case c == '[':
+		return p.array()
+	case c == '"':
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return &object.String{Value: s}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case p.literal("true"):
+		return TRUE, nil
+	case p.literal("false"):
+		return FALSE, nil
+	case p.literal("null"):
+		return VOID, nil
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+func (p *jsonParser) object() (object.Object, error) {
+	m := object.NewMap()
+	p.advance() // {
+
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.advance()
+		return m, nil
+	}
+
+	for {
+		p.skipSpace()
+		if p.peek() != '"' {
+			return nil, p.unexpected()
+		}
+		key, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipSpace()
+		if p.peek() != ':' {
+			return nil, p.unexpected()
+		}
+		p.advance()
+
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		m.Set(key, val)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case '}':
+			p.advance()
+			return m, nil
+		default:
+			return nil, p.unexpected()
+		}
+	}
+}
+
+func (p *jsonParser) array() (object.Object, error) {
+	items := []object.Object{}
+	p.advance() // [
+
+	p.skipSpace()
+	if p.peek() == ']' {
+		p.advance()
+		return &object.ArrayLiteral{Items: items}, nil
+	}
+
+	for {
+		val, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, val)
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.advance()
+		case ']':
+			p.advance()
+			return &object.ArrayLiteral{Items: items}, nil
+		default:
+			return nil, p.unexpected()
+		}
+	}
+}
+
+func (p *jsonParser) number() (object.Object, error) {
+	line, column := p.line, p.column
+	start := p.pos
+
+	if p.peek() == '-' {
+		p.advance()
+	}
+	switch c := p.peek(); {
+	case c == '0':
+		p.advance()
+	case c >= '1' && c <= '9':
+		for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+			p.advance()
+		}
+	default:
+		return nil, p.unexpected()
+	}
+
+	float := false
+	if p.peek() == '.' {
+		float = true
+		p.advance()
+		if err := p.digits(); err != nil {
+			return nil, err
+		}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		float = true
+		p.advance()
+		if c := p.peek(); c == '+' || c == '-' {
+			p.advance()
+		}
+		if err := p.digits(); err != nil {
+			return nil, err
+		}
+	}
+
+	if float {
+		f, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, &jsonError{line: line, column: column, message: fmt.Sprintf("number out of range: %s", p.src[start:p.pos])}
+		}
+		return &object.Float{Value: f}, nil
+	}
+
+	n, err := strconv.ParseInt(p.src[start:p.pos], 10, 64)
+	if err != nil {
+		return nil, &jsonError{line: line, column: column, message: fmt.Sprintf("integer out of range: %s", p.src[start:p.pos])}
+	}
+	return &object.Integer{Value: n}, nil
+}
+
+// digits consumes one or more decimal digits.
+func (p *jsonParser) digits() error {
+	if c := p.peek(); c < '0' || c > '9' {
+		return p.unexpected()
+	}
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.advance()
+	}
+	return nil
+}
+
+func (p *jsonParser) string() (string, error) {
+	var out strings.Builder
+	p.advance() // "
+
+	for {
+		if p.pos >= len(p.src) {
+			return "", p.unexpected()
+		}
+
+		r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+		switch {
+		case r == '"':
+			p.advance()
+			return out.String(), nil
+		case r < 0x20:
+			return "", p.unexpected()
+		case r == '\\':
+			p.advance()
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			out.WriteRune(r)
+		default:
+			p.advance()
+			out.WriteRune(r)
+		}
+	}
+}
+
+// escape decodes an escape sequence after its backslash.
+func (p *jsonParser) escape() (rune, error) {
+	escapes := map[byte]rune{'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t'}
+
+	c := p.peek()
+	if r, ok := escapes[c]; ok {
+		p.advance()
+		return r, nil
+	}
+	if c != 'u' {
+		return 0, p.unexpected()
+	}
+	p.advance()
+
+	r, err := p.hex()
+	if err != nil || !utf16.IsSurrogate(r) {
+		return r, err
+	}
+	if r >= 0xdc00 {
+		// the second half of a pair without the first
+		return utf8.RuneError, nil
+	}
+
+	// the second half of a surrogate pair must follow, otherwise the escape
+	// after the first half is decoded on its own
+	pos, line, column := p.pos, p.line, p.column
+	if !p.literal(`\u`) {
+		return utf8.RuneError, nil
+	}
+	low, err := p.hex()
+	if err != nil {
+		return 0, err
+	}
+	if low < 0xdc00 || low > 0xdfff {
+		p.pos, p.line, p.column = pos, line, column
+		return utf8.RuneError, nil
+	}
+	return utf16.DecodeRune(r, low), nil
+}
+
+func (p *jsonParser) hex() (rune, error) {
+	var r rune
+	for i := 0; i < 4; i++ {
+		c := p.peek()
+		var digit byte
+		switch {
+		case c >= '0' && c <= '9':
+			digit = c - '0'
+		case c >= 'a' && c <= 'f':
+			digit = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			digit = c - 'A' + 10
+		default:
+			return 0, p.unexpected()
+		}
+		r = r*16 + rune(digit)
+		p.advance()
+	}
+	return r, nil
+}
+
+// jsonStringifier encodes objects as JSON, on several lines if pretty.
+// Containers being encoded are kept in seen to detect cycles.
+type jsonStringifier struct {
+	out    strings.Builder
+	pretty bool
+	indent string
+	seen   map[object.Object]bool
+}
+
+func (s *jsonStringifier) newline(depth int) {
+	if s.pretty {
+		s.out.WriteString("\n" + strings.Repeat(s.indent, depth))
+	}
+}
+
+func (s *jsonStringifier) value(obj object.Object, depth int) string {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		s.out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return fmt.Sprintf("cannot serialise %s", obj.Inspect())
+		}
+		s.out.WriteString(obj.Inspect())
+	case *object.Boolean:
+		s.out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Void:
+		s.out.WriteString("null")
+	case *object.String:
+		s.string(obj.Value)
+	case *object.ArrayLiteral:
+		if s.seen[obj] {
+			return "cycle in ARRAY"
+		}
+		s.seen[obj] = true
+		defer delete(s.seen, obj)
+
+		items := obj.Elements()
+		s.out.WriteString("[")
+		for i, item := range items {
+			if i > 0 {
+				s.out.WriteString(",")
+			}
+			s.newline(depth + 1)
+			if err := s.value(item, depth+1); err != "" {
+				return err
+			}
+		}
+		if len(items) > 0 {
+			s.newline(depth)
+		}
+		s.out.WriteString("]")
+	case *object.Map:
+		if s.seen[obj] {
+			return "cycle in MAP"
+		}
+		s.seen[obj] = true
+		defer delete(s.seen, obj)
+
+		s.out.WriteString("{")
+		for i, key := range obj.Keys() {
+			if i > 0 {
+				s.out.WriteString(",")
+			}
+			s.newline(depth + 1)
+			s.string(key)
+			s.out.WriteString(":")
+			if s.pretty {
+				s.out.WriteString(" ")
+			}
+			val, _ := obj.Get(key)
+			if err := s.value(val, depth+1); err != "" {
+				return err
+			}
+		}
+		if obj.Len() > 0 {
+			s.newline(depth)
+		}
+		s.out.WriteString("}")
+	default:
+		return fmt.Sprintf("cannot serialise %s", obj.Type())
+	}
+	return ""
+}
+
+func (s *jsonStringifier) string(str string) {
+	s.out.WriteByte('"')
+	for _, r := range str {
+		switch r {
+		case '"':
+			s.out.WriteString(`\"`)
+		case '\\':
+			s.out.WriteString(`\\`)
+		case '\n':
+			s.out.WriteString(`\n`)
+		case '\r':
+			s.out.WriteString(`\r`)
+		case '\t':
+			s.out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&s.out, `\u%04x`, r)
+			} else {
+				s.out.WriteRune(r)
+			}
+		}
+	}
+	s.out.WriteByte('"')
+}
@@ -0,0 +1,381 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range mathBuiltins {
+		builtins[name] = b
+	}
+}
+
+// builtinConstants are values available everywhere unless shadowed.
+var builtinConstants = map[string]object.Object{
+	"PI": &object.Float{Value: math.Pi},
+	"E":  &object.Float{Value: math.E},
+}
+
+// toFloat converts a number for the functions computed in floating point,
+// which accept integers as well as floats.
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
+var (
+	errDomain   = errors.New("domain error")
+	errOverflow = errors.New("integer overflow")
+)
+
+// mathError reports err for the call of name with args.
+func mathError(err error, name string, token *token.Token, args []object.Object) *object.Error {
+	return newError(token, err.Error()+": "+callString(name, args))
+}
+
+func callString(name string, args []object.Object) string {
+	out := name + "("
+	for i, arg := range args {
+		if i > 0 {
+			out += ", "
+		}
+		out += arg.Inspect()
+	}
+	return out + ")"
+}
+
+// realFunction wraps a function computed in floating point. valid reports
+// whether an argument is in its domain.
+func realFunction(name string, fn func(float64) float64, valid func(float64) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 1, found %d", name, len(args)))
+			}
+
+			x, ok := toFloat(args[0])
+			if !ok {
+				return invalidArguments(name, token, args)
+			}
+			if valid != nil && !valid(x) {
+				return mathError(errDomain, name, token, args)
+			}
+
+			res := fn(x)
+			if math.IsNaN(res) || math.IsInf(res, 0) {
+				return mathError(errDomain, name, token, args)
+			}
+			return &object.Float{Value: res}
+		},
+	}
+}
+
+// roundingFunction wraps a function rounding floats to whole numbers, which
+// are returned as integers. Integers are already whole.
+func roundingFunction(name string, fn func(float64) float64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 1, found %d", name, len(args)))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				res := fn(arg.Value)
+				switch {
+				case math.IsNaN(res) || math.IsInf(res, 0):
+					return mathError(errDomain, name, token, args)
+				case res < math.MinInt64 || res >= math.MaxInt64:
+					return mathError(errOverflow, name, token, args)
+				}
+				return &object.Integer{Value: int64(res)}
+			default:
+				return invalidArguments(name, token, args)
+			}
+		},
+	}
+}
+
+// floatFunction wraps a function of floats failing with errDomain, as it
+// does when the result is not a number or infinite.
+func floatFunction(name string, arity int, fn func(args []float64) (float64, error)) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			types := make([]object.ObjectType, arity)
+			for i := range types {
+				types[i] = object.FLOAT_OBJ
+			}
+			if err := checkArgs(name, token, args, types...); err != nil {
+				return err
+			}
+
+			floats := make([]float64, arity)
+			for i, arg := range args {
+				floats[i] = arg.(*object.Float).Value
+			}
+
+			res, err := fn(floats)
+			if err == nil && (math.IsNaN(res) || math.IsInf(res, 0)) {
+				err = errDomain
+			}
+			if err != nil {
+				return mathError(err, name, token, args)
+			}
+			return &object.Float{Value: res}
+		},
+	}
+}
+
+// overloaded calls floats if any argument is a float and ints otherwise.
+// Integers and floats are not mixed, like in arithmetic.
+func overloaded(ints *object.Builtin, floats *object.Builtin) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			for _, arg := range args {
+				if arg.Type() == object.FLOAT_OBJ {
+					return floats.Fn(env, token, args...)
+				}
+			}
+			return ints.Fn(env, token, args...)
+		},
+	}
+}
+
+// integerFunction wraps a function of integers failing with errDomain or
+// errOverflow.
+func integerFunction(name string, arity int, fn func(args []int64) (int64, error)) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			types := make([]object.ObjectType, arity)
+			for i := range types {
+				types[i] = object.INTEGER_OBJ
+			}
+			if err := checkArgs(name, token, args, types...); err != nil {
+				return err
+			}
+
+			ints := make([]int64, arity)
+			for i, arg := range args {
+				ints[i] = arg.(*object.Integer).Value
+			}
+
+			res, err := fn(ints)
+			if err != nil {
+				return mathError(err, name, token, args)
+			}
+			return &object.Integer{Value: res}
+		},
+	}
+}
+
+// extreme returns a builtin finding the least value, or the greatest one if
+// greatest is set, among its arguments or the values of a single iterable.
+func extreme(name string, greatest bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 0 {
+				return newError(token, fmt.Sprintf("not enough arguments for %s: expected 1, found 0", name))
+			}
+
+			items := args
+			if len(args) == 1 {
+				var err object.Object
+				if items, err = collect(name, args[0], token); err != nil {
+					return err
+				}
+				if len(items) == 0 {
+					return newError(token, fmt.Sprintf("invalid argument for %s: empty sequence", name))
+				}
+			}
+
+			best := items[0]
+			for _, item := range items[1:] {
+				c, ok := compare(item, best)
+				if !ok {
+					return newError(token, fmt.Sprintf("invalid argument for %s: cannot compare %s and %s", name, item.Type(), best.Type()))
+				}
+				if (greatest && c > 0) || (!greatest && c < 0) {
+					best = item
+				}
+			}
+			return best
+		},
+	}
+}
+
+func gcd(a int64, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+var mathBuiltins = map[string]*object.Builtin{
+	"abs": overloaded(integerFunction("abs", 1, func(args []int64) (int64, error) {
+		switch {
+		case args[0] == math.MinInt64:
+			return 0, errOverflow
+		case args[0] < 0:
+			return -args[0], nil
+		default:
+			return args[0], nil
+		}
+	}), floatFunction("abs", 1, func(args []float64) (float64, error) {
+		return math.Abs(args[0]), nil
+	})),
+
+	"min": extreme("min", false),
+	"max": extreme("max", true),
+
+	"clamp": integerFunction("clamp", 3, func(args []int64) (int64, error) {
+		x, lo, hi := args[0], args[1], args[2]
+		switch {
+		case lo > hi:
+			return 0, errDomain
+		case x < lo:
+			return lo, nil
+		case x > hi:
+			return hi, nil
+		default:
+			return x, nil
+		}
+	}),
+
+	"pow": overloaded(integerFunction("pow", 2, func(args []int64) (int64, error) {
+		base, exp := args[0], args[1]
+		if exp < 0 {
+			return 0, errDomain
+		}
+
+		// square and multiply, checking every product for overflow
+		res := int64(1)
+		for exp > 0 {
+			if exp&1 == 1 {
+				if !mulOk(res, base) {
+					return 0, errOverflow
+				}
+				res *= base
+			}
+			exp >>= 1
+			if exp > 0 {
+				if !mulOk(base, base) {
+					return 0, errOverflow
+				}
+				base *= base
+			}
+		}
+		return res, nil
+	}), floatFunction("pow", 2, func(args []float64) (float64, error) {
+		return math.Pow(args[0], args[1]), nil
+	})),
+
+	"sqrt": overloaded(integerFunction("sqrt", 1, func(args []int64) (int64, error) {
+		n := args[0]
+		if n < 0 {
+			return 0, errDomain
+		}
+
+		// the integer square root, corrected for rounding in float64 with
+		// divisions that cannot overflow
+		r := int64(math.Sqrt(float64(n)))
+		for r > 0 && r > n/r {
+			r--
+		}
+		for r+1 <= n/(r+1) {
+			r++
+		}
+		return r, nil
+	}), floatFunction("sqrt", 1, func(args []float64) (float64, error) {
+		if args[0] < 0 {
+			return 0, errDomain
+		}
+		return math.Sqrt(args[0]), nil
+	})),
+
+	"floor": roundingFunction("floor", math.Floor),
+	"ceil":  roundingFunction("ceil", math.Ceil),
+	"round": roundingFunction("round", math.Round),
+
+	"sin":  realFunction("sin", math.Sin, nil),
+	"cos":  realFunction("cos", math.Cos, nil),
+	"tan":  realFunction("tan", math.Tan, nil),
+	"asin": realFunction("asin", math.Asin, inUnitInterval),
+	"acos": realFunction("acos", math.Acos, inUnitInterval),
+	"atan": realFunction("atan", math.Atan, nil),
+	"ln":   realFunction("ln", math.Log, positive),
+
+	// float converts integers to floats
+	"float": realFunction("float", func(x float64) float64 { return x }, nil),
+
+	"log": integerFunction("log", 2, func(args []int64) (int64, error) {
+		n, base := args[0], args[1]
+		if n <= 0 || base < 2 {
+			return 0, errDomain
+		}
+
+		// exact floor of the logarithm
+		res := int64(0)
+		for n >= base {
+			n /= base
+			res++
+		}
+		return res, nil
+	}),
+
+	"gcd": integerFunction("gcd", 2, func(args []int64) (int64, error) {
+		if args[0] == math.MinInt64 || args[1] == math.MinInt64 {
+			return 0, errOverflow
+		}
+		return gcd(args[0], args[1]), nil
+	}),
+
+	"lcm": integerFunction("lcm", 2, func(args []int64) (int64, error) {
+		a, b := args[0], args[1]
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+		if a == math.MinInt64 || b == math.MinInt64 {
+			return 0, errOverflow
+		}
+		if a < 0 {
+			a = -a
+		}
+		if b < 0 {
+			b = -b
+		}
+
+		a /= gcd(a, b)
+		if !mulOk(a, b) {
+			return 0, errOverflow
+		}
+		return a * b, nil
+	}),
+}
+
+func inUnitInterval(x float64) bool { return x >= -1 && x <= 1 }
+func positive(x float64) bool       { return x > 0 }
+
+// mulOk reports whether a * b fits in an int64.
+func mulOk(a int64, b int64) bool {
+	if a == 0 || b == 0 {
+		return true
+	}
+	c := a * b
+	return c/b == a && !(a == -1 && b == math.MinInt64) && !(b == -1 && a == math.MinInt64)
+}
@@ -0,0 +1,120 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range randomBuiltins {
+		builtins[name] = b
+	}
+}
+
+// randRange returns a uniformly random integer between lo and hi inclusive.
+func randRange(rnd *rand.Rand, lo int64, hi int64) int64 {
+	span := uint64(hi-lo) + 1
+	switch {
+	case span == 0:
+		// lo and hi are the least and the greatest integer
+		return int64(rnd.Uint64())
+	case span <= math.MaxInt64:
+		return lo + rnd.Int63n(int64(span))
+	default:
+		for {
+			if n := rnd.Uint64(); n < span {
+				return lo + int64(n)
+			}
+		}
+	}
+}
+
+var randomBuiltins = map[string]*object.Builtin{
+	// random returns a random non-negative integer
+	"random": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("random", token, args); err != nil {
+				return err
+			}
+
+			var n int64
+			env.Runtime().Random(func(rnd *rand.Rand) { n = rnd.Int63() })
+			return &object.Integer{Value: n}
+		},
+	},
+
+	// rand_int returns a random integer between lo and hi inclusive
+	"rand_int": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("rand_int", token, args, object.INTEGER_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			lo, hi := args[0].(*object.Integer).Value, args[1].(*object.Integer).Value
+			if lo > hi {
+				return newError(token, fmt.Sprintf("invalid argument for rand_int: %d is greater than %d", lo, hi))
+			}
+
+			var n int64
+			env.Runtime().Random(func(rnd *rand.Rand) { n = randRange(rnd, lo, hi) })
+			return &object.Integer{Value: n}
+		},
+	},
+
+	// choice returns a random value of an iterable
+	"choice": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for choice: expected 1, found %d", len(args)))
+			}
+
+			items, err := collect("choice", args[0], token)
+			if err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				return newError(token, "invalid argument for choice: empty sequence")
+			}
+
+			var i int
+			env.Runtime().Random(func(rnd *rand.Rand) { i = rnd.Intn(len(items)) })
+			return items[i]
+		},
+	},
+
+	// shuffle returns the values of an iterable in random order
+	"shuffle": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for shuffle: expected 1, found %d", len(args)))
+			}
+
+			items, err := collect("shuffle", args[0], token)
+			if err != nil {
+				return err
+			}
+
+			env.Runtime().Random(func(rnd *rand.Rand) {
+				rnd.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+			})
+			return &object.ArrayLiteral{Items: items}
+		},
+	},
+
+	// seed makes the random builtins repeat the sequence they produce after
+	// the same seed
+	"seed": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("seed", token, args, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			env.Runtime().Seed(args[0].(*object.Integer).Value)
+			return VOID
+		},
+	},
+}
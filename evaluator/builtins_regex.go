@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"regexp"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range regexBuiltins {
+		builtins[name] = b
+	}
+}
+
+// regexFunction wraps a function of a regex and a string.
+func regexFunction(name string, fn func(re *regexp.Regexp, s string) object.Object) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs(name, token, args, object.REGEX_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return fn(args[0].(*object.Regex).Regexp, args[1].(*object.String).Value)
+		},
+	}
+}
+
+var regexBuiltins = map[string]*object.Builtin{
+	"regex": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("regex", token, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			re, err := regexp.Compile(args[0].(*object.String).Value)
+			if err != nil {
+				return newError(token, "invalid argument for regex: "+err.Error())
+			}
+			return &object.Regex{Regexp: re}
+		},
+	},
+
+	// match reports whether the regex matches anywhere in the string
+	"match": regexFunction("match", func(re *regexp.Regexp, s string) object.Object {
+		return newBoolean(re.MatchString(s))
+	}),
+
+	// find_all returns the successive non-overlapping matches
+	"find_all": regexFunction("find_all", func(re *regexp.Regexp, s string) object.Object {
+		return stringArray(re.FindAllString(s, -1))
+	}),
+
+	// captures returns the first match followed by the text of its groups,
+	// which is empty for groups that did not participate, or an empty array
+	// if there is no match
+	"captures": regexFunction("captures", func(re *regexp.Regexp, s string) object.Object {
+		return stringArray(re.FindStringSubmatch(s))
+	}),
+}
@@ -0,0 +1,259 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range stringBuiltins {
+		builtins[name] = b
+	}
+}
+
+// checkArgs reports an error unless args have exactly the given types.
+func checkArgs(name string, token *token.Token, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected %d, found %d", name, len(types), len(args)))
+	}
+	for i, arg := range args {
+		if arg.Type() != types[i] {
+			return invalidArguments(name, token, args)
+		}
+	}
+	return nil
+}
+
+// invalidArguments reports that name was called with arguments of the wrong
+// types, listing all of them.
+func invalidArguments(name string, token *token.Token, args []object.Object) *object.Error {
+	argTypes := []string{}
+	for _, arg := range args {
+		argTypes = append(argTypes, string(arg.Type()))
+	}
+	return newError(token, fmt.Sprintf("invalid argument: %s(%s)", name, strings.Join(argTypes, ", ")))
+}
+
+// stringFunction wraps a function of strings returning a string.
+func stringFunction(name string, fn func(args ...string) string, arity int) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			types := make([]object.ObjectType, arity)
+			for i := range types {
+				types[i] = object.STRING_OBJ
+			}
+			if err := checkArgs(name, token, args, types...); err != nil {
+				return err
+			}
+
+			strs := make([]string, arity)
+			for i, arg := range args {
+				strs[i] = arg.(*object.String).Value
+			}
+			return &object.String{Value: fn(strs...)}
+		},
+	}
+}
+
+// stringPredicate wraps a test of a string against another.
+func stringPredicate(name string, fn func(s string, sub string) bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs(name, token, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return newBoolean(fn(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	}
+}
+
+// stringArray converts strs to an array of strings.
+func stringArray(strs []string) *object.ArrayLiteral {
+	items := make([]object.Object, len(strs))
+	for i, s := range strs {
+		items[i] = &object.String{Value: s}
+	}
+	return &object.ArrayLiteral{Items: items}
+}
+
+// maxStringLength bounds the length in bytes of the strings built by repeat
+// and pad, so that huge counts fail instead of exhausting memory.
+const maxStringLength = 1 << 28
+
+// tooLong reports that the result of name would exceed maxStringLength.
+func tooLong(name string, token *token.Token) *object.Error {
+	return newError(token, fmt.Sprintf("invalid argument for %s: result longer than %d bytes", name, maxStringLength))
+}
+
+// pad returns a builtin padding a string to a width in runes with a fill
+// string, a space by default, on the left or the right.
+func pad(name string, left bool) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			types := []object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ, object.STRING_OBJ}
+			if len(args) == 2 {
+				types = types[:2]
+			}
+			if len(args) < 2 || len(args) > 3 {
+				return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 2 or 3, found %d", name, len(args)))
+			}
+			if err := checkArgs(name, token, args, types...); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			width := args[1].(*object.Integer).Value
+			fill := " "
+			if len(args) == 3 {
+				fill = args[2].(*object.String).Value
+			}
+			if fill == "" {
+				return newError(token, fmt.Sprintf("invalid argument for %s: fill cannot be empty", name))
+			}
+
+			missing := width - int64(utf8.RuneCountInString(s))
+			if missing <= 0 {
+				return args[0]
+			}
+
+			// the fill is repeated whole and then cut to the missing runes
+			fillRunes := []rune(fill)
+			whole, part := missing/int64(len(fillRunes)), string(fillRunes[:missing%int64(len(fillRunes))])
+			if whole > int64((maxStringLength-len(s)-len(part))/len(fill)) {
+				return tooLong(name, token)
+			}
+			padding := strings.Repeat(fill, int(whole)) + part
+
+			if left {
+				return &object.String{Value: padding + s}
+			}
+			return &object.String{Value: s + padding}
+		},
+	}
+}
+
+var stringBuiltins = map[string]*object.Builtin{
+	// split separates a string at a separator string or at the matches of
+	// a regex
+	"split": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 2 && args[1].Type() == object.REGEX_OBJ {
+				if err := checkArgs("split", token, args, object.STRING_OBJ, object.REGEX_OBJ); err != nil {
+					return err
+				}
+				return stringArray(args[1].(*object.Regex).Regexp.Split(args[0].(*object.String).Value, -1))
+			}
+
+			if err := checkArgs("split", token, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return stringArray(strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+
+	"join": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("join", token, args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			items := args[0].(*object.ArrayLiteral).Elements()
+			strs := make([]string, len(items))
+			for i, item := range items {
+				s, ok := item.(*object.String)
+				if !ok {
+					return newError(token, fmt.Sprintf("invalid argument for join: item %d is not a string", i))
+				}
+				strs[i] = s.Value
+			}
+			return &object.String{Value: strings.Join(strs, args[1].(*object.String).Value)}
+		},
+	},
+
+	"trim": stringFunction("trim", func(args ...string) string {
+		return strings.TrimSpace(args[0])
+	}, 1),
+
+	"upper": stringFunction("upper", func(args ...string) string {
+		return strings.ToUpper(args[0])
+	}, 1),
+
+	"lower": stringFunction("lower", func(args ...string) string {
+		return strings.ToLower(args[0])
+	}, 1),
+
+	// replace replaces all occurrences of a string or all matches of a
+	// regex, in which case $1 or ${name} in the replacement expand to the
+	// text of a group
+	"replace": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if len(args) == 3 && args[1].Type() == object.REGEX_OBJ {
+				if err := checkArgs("replace", token, args, object.STRING_OBJ, object.REGEX_OBJ, object.STRING_OBJ); err != nil {
+					return err
+				}
+				re := args[1].(*object.Regex).Regexp
+				return &object.String{Value: re.ReplaceAllString(args[0].(*object.String).Value, args[2].(*object.String).Value)}
+			}
+
+			if err := checkArgs("replace", token, args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			s, old, new := args[0].(*object.String).Value, args[1].(*object.String).Value, args[2].(*object.String).Value
+			return &object.String{Value: strings.ReplaceAll(s, old, new)}
+		},
+	},
+
+	"contains":    stringPredicate("contains", strings.Contains),
+	"starts_with": stringPredicate("starts_with", strings.HasPrefix),
+	"ends_with":   stringPredicate("ends_with", strings.HasSuffix),
+
+	"index_of": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("index_of", token, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			// indices count runes like string indexing does
+			s := args[0].(*object.String).Value
+			idx := strings.Index(s, args[1].(*object.String).Value)
+			if idx < 0 {
+				return &object.Integer{Value: -1}
+			}
+			return &object.Integer{Value: int64(utf8.RuneCountInString(s[:idx]))}
+		},
+	},
+
+	"repeat": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("repeat", token, args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			s := args[0].(*object.String).Value
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError(token, fmt.Sprintf("invalid argument for repeat: negative count %d", count))
+			}
+			if s != "" && count > int64(maxStringLength/len(s)) {
+				return tooLong("repeat", token)
+			}
+			return &object.String{Value: strings.Repeat(s, int(count))}
+		},
+	},
+
+	"pad_left":  pad("pad_left", true),
+	"pad_right": pad("pad_right", false),
+
+	"chars": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("chars", token, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return stringArray(strings.Split(args[0].(*object.String).Value, ""))
+		},
+	},
+}
@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+func init() {
+	for name, b := range timeBuiltins {
+		builtins[name] = b
+	}
+	for name, c := range timeConstants {
+		builtinConstants[name] = c
+	}
+}
+
+// Times are integers counting milliseconds since the Unix epoch and
+// durations are integers counting milliseconds, so they are added and
+// compared like other numbers. The constants help write durations.
+var timeConstants = map[string]object.Object{
+	"SECOND": &object.Integer{Value: 1000},
+	"MINUTE": &object.Integer{Value: 60 * 1000},
+	"HOUR":   &object.Integer{Value: 60 * 60 * 1000},
+	"DAY":    &object.Integer{Value: 24 * 60 * 60 * 1000},
+}
+
+// defaultLayout is used to format and parse times when no layout is given.
+// Layouts are written the way Go writes them.
+const defaultLayout = time.RFC3339
+
+// toDuration converts milliseconds to a duration, failing with errOverflow if
+// they are out of its range.
+func toDuration(ms int64) (time.Duration, error) {
+	if ms > math.MaxInt64/int64(time.Millisecond) || ms < math.MinInt64/int64(time.Millisecond) {
+		return 0, errOverflow
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// layoutArgs checks the arguments of a builtin taking a value of type first
+// and an optional layout, and returns the layout.
+func layoutArgs(name string, token *token.Token, args []object.Object, first object.ObjectType) (string, *object.Error) {
+	if len(args) < 1 || len(args) > 2 {
+		return "", newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 1 or 2, found %d", name, len(args)))
+	}
+
+	if args[0].Type() != first {
+		return "", invalidArguments(name, token, args)
+	}
+	if len(args) == 1 {
+		return defaultLayout, nil
+	}
+	layout, ok := args[1].(*object.String)
+	if !ok {
+		return "", invalidArguments(name, token, args)
+	}
+	return layout.Value, nil
+}
+
+// timeFunction wraps a function of no arguments returning an integer read
+// from the runtime.
+func timeFunction(name string, fn func(r *object.Runtime) int64) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs(name, token, args); err != nil {
+				return err
+			}
+			return &object.Integer{Value: fn(env.Runtime())}
+		},
+	}
+}
+
+var timeBuiltins = map[string]*object.Builtin{
+	// now returns the current time in milliseconds since the Unix epoch
+	"now": timeFunction("now", func(r *object.Runtime) int64 {
+		return r.Clock.Now().UnixMilli()
+	}),
+
+	// unix returns the current time in seconds since the Unix epoch
+	"unix": timeFunction("unix", func(r *object.Runtime) int64 {
+		return r.Clock.Now().Unix()
+	}),
+
+	// clock returns milliseconds on a monotonic clock, for timing
+	"clock": timeFunction("clock", func(r *object.Runtime) int64 {
+		return r.Elapsed().Milliseconds()
+	}),
+
+	"sleep": {
+		Fn: func(env *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("sleep", token, args, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			ms := args[0].(*object.Integer).Value
+			if ms < 0 {
+				return newError(token, fmt.Sprintf("invalid argument for sleep: negative duration %d", ms))
+			}
+			d, err := toDuration(ms)
+			if err != nil {
+				return mathError(err, "sleep", token, args)
+			}
+			env.Runtime().Clock.Sleep(d)
+			return VOID
+		},
+	},
+
+	// format_time formats a time in UTC
+	"format_time": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			layout, err := layoutArgs("format_time", token, args, object.INTEGER_OBJ)
+			if err != nil {
+				return err
+			}
+
+			t := time.UnixMilli(args[0].(*object.Integer).Value).UTC()
+			return &object.String{Value: t.Format(layout)}
+		},
+	},
+
+	// parse_time parses a time, in UTC unless the layout has a zone
+	"parse_time": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			layout, err := layoutArgs("parse_time", token, args, object.STRING_OBJ)
+			if err != nil {
+				return err
+			}
+
+			t, perr := time.Parse(layout, args[0].(*object.String).Value)
+			if perr != nil {
+				return newError(token, "invalid argument for parse_time: "+perr.Error())
+			}
+			return &object.Integer{Value: t.UnixMilli()}
+		},
+	},
+
+	// duration parses a duration such as "1h30m" into milliseconds
+	"duration": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("duration", token, args, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			d, err := time.ParseDuration(args[0].(*object.String).Value)
+			if err != nil {
+				return newError(token, "invalid argument for duration: "+err.Error())
+			}
+			return &object.Integer{Value: d.Milliseconds()}
+		},
+	},
+
+	// format_duration formats milliseconds as a duration such as "1h30m0s"
+	"format_duration": {
+		Fn: func(_ *object.Environment, token *token.Token, args ...object.Object) object.Object {
+			if err := checkArgs("format_duration", token, args, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			d, err := toDuration(args[0].(*object.Integer).Value)
+			if err != nil {
+				return mathError(err, "format_duration", token, args)
+			}
+			return &object.String{Value: d.String()}
+		},
+	},
+}
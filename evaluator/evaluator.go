@@ -5,6 +5,7 @@ import (
 	"baboon/object"
 	"baboon/token"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -35,6 +36,16 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 
+	case *ast.FunctionDeclaration:
+		// bound by hoistFunctions before the block runs
+		return VOID
+
+	case *ast.DeferStatement:
+		if !env.Defer(func() object.Object { return Eval(node.Value, env) }) {
+			return newError(&node.Token, "defer outside of function")
+		}
+		return VOID
+
 	case *ast.ReturnStatement:
 		val := Eval(node.Value, env)
 		if val.Type() == object.ERROR_OBJ {
@@ -70,6 +81,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -79,6 +93,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.AssignExpression:
 		return evalAssignExpression(node, env)
 
+	case *ast.IndexAssignExpression:
+		return evalIndexAssignExpression(node, env)
+
 	case *ast.Identifier:
 		if val, ok := env.Get(node.Value); ok {
 			return val
@@ -86,14 +103,45 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if builtin, ok := builtins[node.Value]; ok {
 			return builtin
 		}
+		if constant, ok := builtinConstants[node.Value]; ok {
+			return constant
+		}
 		return newError(&node.Token, fmt.Sprintf("identifier not found: %s", node.Value))
 
 	case *ast.FunctionExpression:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Body: body, Env: env, Generator: node.Generator}
+
+	case *ast.YieldExpression:
+		val := Eval(node.Value, env)
+		if val.Type() == object.ERROR_OBJ {
+			return val
+		}
+		yield, ok := env.Yield()
+		if !ok {
+			return newError(&node.Token, "yield outside of generator")
+		}
+		if !yield(val) {
+			return newError(&node.Token, "generator abandoned")
+		}
+		return VOID
+
+	case *ast.MacroLiteral:
+		return newError(&node.Token, "macro can only be defined at the top level")
 
 	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError(&node.Token, fmt.Sprintf("wrong number of arguments for quote: expected 1, found %d", len(node.Arguments)))
+			}
+			return quote(node.Arguments[0], env)
+		}
+
+		if dot, ok := node.Function.(*ast.DotExpression); ok {
+			return evalMethodCall(node, dot, env)
+		}
+
 		fn := Eval(node.Function, env)
 		if fn.Type() == object.ERROR_OBJ {
 			return fn
@@ -104,7 +152,41 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return args[0]
 		}
 
-		return applyFunction(fn, args, &node.Token)
+		return applyFunction(fn, args, env, callToken(node, fn))
+
+	case *ast.SpawnExpression:
+		fn := Eval(node.Call.Function, env)
+		if fn.Type() == object.ERROR_OBJ {
+			return fn
+		}
+
+		args := evalExpressions(node.Call.Arguments, env)
+		if len(args) == 1 && args[0].Type() == object.ERROR_OBJ {
+			return args[0]
+		}
+
+		return env.Runtime().Scheduler.Spawn(func() object.Object {
+			return applyFunction(fn, args, env, &node.Call.Token)
+		})
+
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
+
+	case *ast.EnumLiteral:
+		return evalEnumLiteral(node, env)
+
+	case *ast.ClassLiteral:
+		return evalClassLiteral(node, env)
+
+	case *ast.FieldAssignExpression:
+		return evalFieldAssignExpression(node, env)
+
+	case *ast.CaseExpression:
+		subject := Eval(node.Subject, env)
+		if subject.Type() == object.ERROR_OBJ {
+			return subject
+		}
+		return evalCaseExpression(node, subject, env)
 
 	case *ast.ArrayLiteral:
 		items := evalExpressions(node.Items, env)
@@ -114,6 +196,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return &object.ArrayLiteral{Items: items}
 
+	case *ast.MapLiteral:
+		return evalMapLiteral(node, env)
+
+	case *ast.Comprehension:
+		return evalComprehension(node, env)
+
+	case *ast.RangeExpression:
+		return evalRangeExpression(node, env)
+
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if left.Type() == object.ERROR_OBJ {
+			return left
+		}
+		if f, ok := left.(object.Fielder); ok {
+			if field, ok := f.Field(node.Name.Value); ok {
+				return field
+			}
+		}
+		return newError(&node.Token, fmt.Sprintf("%s has no field %s", left.Type(), node.Name.Value))
+
 	case *ast.AccessExpression:
 		arr := Eval(node.Array, env)
 		if arr.Type() == object.ERROR_OBJ {
@@ -134,6 +237,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 }
 
 func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+	if err := hoistFunctions(stmts, env); err != nil {
+		return err
+	}
+
 	var result object.Object = VOID
 
 	for _, stmt := range stmts {
@@ -150,6 +257,10 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 }
 
 func evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.Object {
+	if err := hoistFunctions(stmts, env); err != nil {
+		return err
+	}
+
 	var result object.Object = VOID
 
 	for _, stmt := range stmts {
@@ -162,6 +273,25 @@ func evalBlockStatement(stmts []ast.Statement, env *object.Environment) object.O
 	return result
 }
 
+// hoistFunctions binds the functions declared in stmts before any of them
+// run, so that declarations can refer to each other regardless of order.
+func hoistFunctions(stmts []ast.Statement, env *object.Environment) *object.Error {
+	for _, stmt := range stmts {
+		decl, ok := stmt.(*ast.FunctionDeclaration)
+		if !ok {
+			continue
+		}
+
+		if _, declared := env.Get(decl.Name.Value); declared {
+			return newError(&decl.Name.Token, fmt.Sprintf("identifier already declared: %s", decl.Name.Value))
+		}
+
+		fn := decl.Function
+		env.SetConst(decl.Name.Value, &object.Function{Name: decl.Name.Value, Parameters: fn.Parameters, Body: fn.Body, Env: env, Generator: fn.Generator})
+	}
+	return nil
+}
+
 func evalPrefixExpression(op string, right object.Object, token *token.Token) object.Object {
 	switch op {
 	case "!":
@@ -187,26 +317,42 @@ func evalBangExpression(obj object.Object, token *token.Token) object.Object {
 }
 
 func evalMinusPrefixExpression(obj object.Object, token *token.Token) object.Object {
-	if obj.Type() != object.INTEGER_OBJ {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -obj.Value}
+	case *object.Float:
+		return &object.Float{Value: -obj.Value}
+	default:
 		return newError(token, fmt.Sprintf("unknown operator: -%s", obj.Type()))
 	}
-
-	value := obj.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(op string, left object.Object, right object.Object, token *token.Token) object.Object {
+	_, leftMethods := left.(object.Methoder)
+	_, rightMethods := right.(object.Methoder)
+	if (leftMethods || rightMethods) && (op == "==" || op == "!=") {
+		res := equal(left, right, token)
+		if op == "!=" && res.Type() == object.BOOLEAN_OBJ {
+			return newBoolean(res != TRUE)
+		}
+		return res
+	}
+
 	switch {
+	case op == "in":
+		return evalInExpression(left, right, token)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerExpression(op, left, right, token)
+	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
+		return evalFloatExpression(op, left, right, token)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringExpression(op, left, right, token)
 	case left.Type() != right.Type():
 		return newError(token, fmt.Sprintf("type mismatch: %s %s %s", left.Type(), op, right.Type()))
 	case op == "==":
-		return newBoolean(left == right)
+		return newBoolean(object.Equal(left, right))
 	case op == "!=":
-		return newBoolean(left != right)
+		return newBoolean(!object.Equal(left, right))
 	default:
 		return newError(token, fmt.Sprintf("unknown operator: %s %s %s", left.Type(), op, right.Type()))
 	}
@@ -242,6 +388,38 @@ func evalIntegerExpression(op string, left object.Object, right object.Object, t
 	}
 }
 
+// evalFloatExpression follows IEEE 754, so that dividing by zero gives an
+// infinity rather than an error.
+func evalFloatExpression(op string, left object.Object, right object.Object, token *token.Token) object.Object {
+	leftVal := left.(*object.Float).Value
+	rightVal := right.(*object.Float).Value
+
+	switch op {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return newBoolean(leftVal < rightVal)
+	case ">":
+		return newBoolean(leftVal > rightVal)
+	case "<=":
+		return newBoolean(leftVal <= rightVal)
+	case ">=":
+		return newBoolean(leftVal >= rightVal)
+	case "==":
+		return newBoolean(leftVal == rightVal)
+	case "!=":
+		return newBoolean(leftVal != rightVal)
+	default:
+		return newError(token, fmt.Sprintf("unknown operator: %s %s %s", left.Type(), op, right.Type()))
+	}
+}
+
 func evalStringExpression(op string, left object.Object, right object.Object, token *token.Token) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -286,24 +464,137 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) []ob
 	return res
 }
 
-func applyFunction(fn object.Object, args []object.Object, token *token.Token) object.Object {
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment, token *token.Token) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			name := fn.Name
+			if name == "" {
+				name = "fn"
+			}
+			return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected %d, found %d", name, len(fn.Parameters), len(args)))
+		}
 		extEnv := extendFnEnv(fn, args)
+		if fn.Generator {
+			// recursive calls yield on behalf of the running generator
+			yield, running := env.YieldOf(fn.Body)
+			if !running {
+				return newGenerator(fn, extEnv)
+			}
+			extEnv.SetYield(yield, fn.Body)
+		}
 		evaluated := Eval(fn.Body, extEnv)
 		if val, ok := evaluated.(*object.Return); ok {
-			return val.Value
+			evaluated = val.Value
 		}
-		return evaluated
+		return runDeferred(extEnv, evaluated)
 	case *object.Builtin:
-		return fn.Fn(token, args...)
+		return fn.Fn(env, token, args...)
+	case *object.Class:
+		return construct(fn, args, env, token)
+	case *object.Constructor:
+		if len(args) != len(fn.Fields) {
+			return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected %d, found %d", fn.Name, len(fn.Fields), len(args)))
+		}
+		return &object.Variant{Constructor: fn, Values: args}
+	case *object.UserType:
+		if len(args) != 1 {
+			return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 1, found %d", fn.Name, len(args)))
+		}
+		return &object.Typed{UserType: fn, Value: args[0]}
 	default:
+		if res, ok := callProtocol(fn, "__call", args, token); ok {
+			return res
+		}
 		return newError(token, fmt.Sprintf("not a function: %s", fn.Type()))
 	}
 }
 
+// evalMethodCall calls the field of the receiver named by dot or, failing
+// that, the function of that name with the receiver as its first argument.
+// callToken returns the token errors raised by the call node of fn are
+// reported at. Patterns passed to regex as literals are reported at the
+// literal, as the checker does, and other calls at their parenthesis.
+func callToken(node *ast.CallExpression, fn object.Object) *token.Token {
+	if fn == builtins["regex"] && len(node.Arguments) == 1 {
+		if lit, ok := node.Arguments[0].(*ast.StringLiteral); ok {
+			return &lit.Token
+		}
+	}
+	return &node.Token
+}
+
+func evalMethodCall(node *ast.CallExpression, dot *ast.DotExpression, env *object.Environment) object.Object {
+	receiver := Eval(dot.Left, env)
+	if receiver.Type() == object.ERROR_OBJ {
+		return receiver
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && args[0].Type() == object.ERROR_OBJ {
+		return args[0]
+	}
+
+	name := dot.Name.Value
+	if f, ok := receiver.(object.Fielder); ok {
+		if method, ok := f.Field(name); ok {
+			return applyFunction(method, args, env, &node.Token)
+		}
+	}
+
+	fn, ok := env.Get(name)
+	if !ok {
+		fn, ok = builtins[name]
+	}
+	if !ok {
+		return newError(&dot.Token, fmt.Sprintf("%s has no field %s and identifier not found: %s", receiver.Type(), name, name))
+	}
+
+	return applyFunction(fn, append([]object.Object{receiver}, args...), env, &node.Token)
+}
+
+func newGenerator(fn *object.Function, env *object.Environment) *object.Generator {
+	return env.Runtime().NewGenerator(func(yield func(object.Object) bool) object.Object {
+		env.SetYield(yield, fn.Body)
+		evaluated := Eval(fn.Body, env)
+		if val, ok := evaluated.(*object.Return); ok {
+			evaluated = val.Value
+		}
+		return runDeferred(env, evaluated)
+	})
+}
+
+// runDeferred runs the calls deferred in the function call of env and
+// returns its result. Errors raised by deferred calls are attached to the
+// error the call failed with, or returned in its place if it succeeded.
+func runDeferred(env *object.Environment, result object.Object) object.Object {
+	errors := []*object.Error{}
+	for _, call := range env.Deferred() {
+		if err, ok := call().(*object.Error); ok {
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) == 0 {
+		return result
+	}
+
+	failure, ok := result.(*object.Error)
+	if !ok {
+		failure, errors = errors[0], errors[1:]
+	}
+	if len(errors) == 0 {
+		return failure
+	}
+
+	combined := *failure
+	combined.Deferred = append(append([]*object.Error{}, failure.Deferred...), errors...)
+	return &combined
+}
+
 func extendFnEnv(fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
+	env.StartCall()
 
 	for i, param := range fn.Parameters {
 		env.Set(param.Value, args[i])
@@ -316,27 +607,208 @@ func evalAccessExpression(arr object.Object, key object.Object, token *token.Tok
 	switch {
 	case arr.Type() == object.ARRAY_OBJ && key.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(arr, key, token)
+	case arr.Type() == object.STRING_OBJ && key.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(arr, key, token)
+	case arr.Type() == object.RANGE_OBJ && key.Type() == object.INTEGER_OBJ:
+		return evalRangeIndexExpression(arr, key, token)
+	case arr.Type() == object.MAP_OBJ && key.Type() == object.STRING_OBJ:
+		val, ok := arr.(*object.Map).Get(key.(*object.String).Value)
+		if !ok {
+			return newError(token, fmt.Sprintf("key not found: %s", key.Inspect()))
+		}
+		return val
 	default:
 		return newError(token, fmt.Sprintf("invalid argument: %s[%s]", arr.Type(), key.Type()))
 	}
 }
 
 func evalArrayIndexExpression(arr object.Object, key object.Object, token *token.Token) object.Object {
-	items := arr.(*object.ArrayLiteral).Items
+	array := arr.(*object.ArrayLiteral)
+	idx := key.(*object.Integer).Value
+
+	length := int64(array.Len())
+
+	switch {
+	case idx < 0 && length+idx >= 0:
+		return array.Get(int(length + idx))
+	case idx >= 0 && idx < length:
+		return array.Get(int(idx))
+	default:
+		return newError(token, fmt.Sprintf("invalid argument: index %d out of bounds", idx))
+	}
+}
+
+func evalStringIndexExpression(str object.Object, key object.Object, token *token.Token) object.Object {
+	runes := []rune(str.(*object.String).Value)
 	idx := key.(*object.Integer).Value
 
-	length := int64(len(items))
+	length := int64(len(runes))
 
 	switch {
-	case idx < 0 && length+idx > 0:
-		return items[length+idx]
+	case idx < 0 && length+idx >= 0:
+		return &object.String{Value: string(runes[length+idx])}
 	case idx >= 0 && idx < length:
-		return items[idx]
+		return &object.String{Value: string(runes[idx])}
 	default:
 		return newError(token, fmt.Sprintf("invalid argument: index %d out of bounds", idx))
 	}
 }
 
+// evalComprehension binds every value of the iterable in a fresh enclosed
+// environment, so that the variable neither leaks nor is shared by closures
+// created in different iterations.
+func evalComprehension(node *ast.Comprehension, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if iterable.Type() == object.ERROR_OBJ {
+		return iterable
+	}
+
+	it, ok := iterate(iterable, &node.Token)
+	if !ok {
+		return newError(&node.Token, fmt.Sprintf("invalid argument: cannot iterate over %s", iterable.Type()))
+	}
+
+	items := []object.Object{}
+	for val, ok := it.Next(); ok; val, ok = it.Next() {
+		if val.Type() == object.ERROR_OBJ {
+			return val
+		}
+
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(node.Variable.Value, val)
+
+		if node.Condition != nil {
+			cond := Eval(node.Condition, iterEnv)
+			if cond.Type() == object.ERROR_OBJ {
+				object.Stop(it)
+				return cond
+			}
+			if cond != TRUE && cond != FALSE {
+				object.Stop(it)
+				return newError(&node.Token, fmt.Sprintf("non-boolean condition in comprehension: %s", cond.Type()))
+			}
+			if cond == FALSE {
+				continue
+			}
+		}
+
+		item := Eval(node.Element, iterEnv)
+		if item.Type() == object.ERROR_OBJ {
+			object.Stop(it)
+			return item
+		}
+		items = append(items, item)
+	}
+
+	return &object.ArrayLiteral{Items: items}
+}
+
+func evalRangeIndexExpression(rng object.Object, key object.Object, token *token.Token) object.Object {
+	r := rng.(*object.Range)
+	idx := key.(*object.Integer).Value
+
+	n, ok := r.Index(idx)
+	if !ok {
+		return newError(token, fmt.Sprintf("invalid argument: index %d out of bounds", idx))
+	}
+	return &object.Integer{Value: n}
+}
+
+func evalRangeExpression(node *ast.RangeExpression, env *object.Environment) object.Object {
+	bounds := []ast.Expression{node.Start, node.End}
+	if node.Step != nil {
+		bounds = append(bounds, node.Step)
+	}
+
+	values := evalExpressions(bounds, env)
+	if len(values) == 1 && values[0].Type() == object.ERROR_OBJ {
+		return values[0]
+	}
+
+	ints := []int64{}
+	for _, val := range values {
+		n, ok := val.(*object.Integer)
+		if !ok {
+			return newError(&node.Token, fmt.Sprintf("invalid range: %s%s%s", values[0].Type(), node.Token.Literal, values[1].Type()))
+		}
+		ints = append(ints, n.Value)
+	}
+
+	r := &object.Range{Start: ints[0], End: ints[1], Step: 1, Inclusive: node.Inclusive}
+	if len(ints) == 3 {
+		if ints[2] == 0 {
+			return newError(&node.Token, "invalid range: step cannot be 0")
+		}
+		r.Step = ints[2]
+	}
+	return r
+}
+
+// evalInExpression tests membership of needle in an array, range, the keys
+// of a map or, for substrings, a string.
+func evalInExpression(needle object.Object, haystack object.Object, token *token.Token) object.Object {
+	if m, ok := haystack.(object.Methoder); ok {
+		if _, ok := m.Method("__iter"); ok {
+			var found object.Object = FALSE
+			err := each("in", haystack, token, func(item object.Object) (bool, object.Object) {
+				found = equal(needle, item, token)
+				if found.Type() == object.ERROR_OBJ {
+					return false, found
+				}
+				return found == FALSE, nil
+			})
+			if err != nil {
+				return err
+			}
+			return found
+		}
+	}
+
+	switch haystack := haystack.(type) {
+	case *object.ArrayLiteral:
+		for _, item := range haystack.Elements() {
+			if eq := equal(needle, item, token); eq != FALSE {
+				return eq
+			}
+		}
+		return FALSE
+	case *object.Range:
+		n, ok := needle.(*object.Integer)
+		return newBoolean(ok && haystack.Contains(n.Value))
+	case *object.String:
+		if s, ok := needle.(*object.String); ok {
+			return newBoolean(strings.Contains(haystack.Value, s.Value))
+		}
+	case *object.Map:
+		if s, ok := needle.(*object.String); ok {
+			_, found := haystack.Get(s.Value)
+			return newBoolean(found)
+		}
+	}
+	return newError(token, fmt.Sprintf("unknown operator: %s in %s", needle.Type(), haystack.Type()))
+}
+
+func evalMapLiteral(node *ast.MapLiteral, env *object.Environment) object.Object {
+	m := object.NewMap()
+	for i, k := range node.Keys {
+		key := Eval(k, env)
+		if key.Type() == object.ERROR_OBJ {
+			return key
+		}
+		s, ok := key.(*object.String)
+		if !ok {
+			return newError(&node.Token, fmt.Sprintf("invalid map key: %s", key.Type()))
+		}
+
+		val := Eval(node.Values[i], env)
+		if val.Type() == object.ERROR_OBJ {
+			return val
+		}
+		m.Set(s.Value, val)
+	}
+	return m
+}
+
 func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
 	_, declared := env.Get(node.Name.Value)
 
@@ -360,8 +832,258 @@ func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) o
 	}
 
 	if node.Token.Type == token.CONST {
-		return env.SetConst(node.Name.Value, val)
+		return env.SetConst(node.Name.Value, object.Freeze(val))
 	} else {
 		return env.Set(node.Name.Value, val)
 	}
 }
+
+func evalIndexAssignExpression(node *ast.IndexAssignExpression, env *object.Environment) object.Object {
+	arr := Eval(node.Target.Array, env)
+	if arr.Type() == object.ERROR_OBJ {
+		return arr
+	}
+
+	key := Eval(node.Target.Key, env)
+	if key.Type() == object.ERROR_OBJ {
+		return key
+	}
+
+	val := Eval(node.Value, env)
+	if val.Type() == object.ERROR_OBJ {
+		return val
+	}
+
+	if m, ok := arr.(*object.Map); ok && key.Type() == object.STRING_OBJ {
+		if m.Frozen {
+			return newError(&node.Token, fmt.Sprintf("cannot mutate frozen %s", arr.Type()))
+		}
+		m.Set(key.(*object.String).Value, val)
+		return val
+	}
+
+	array, ok := arr.(*object.ArrayLiteral)
+	if !ok || key.Type() != object.INTEGER_OBJ {
+		return newError(&node.Token, fmt.Sprintf("invalid argument: %s[%s] = %s", arr.Type(), key.Type(), val.Type()))
+	}
+	if array.Frozen {
+		return newError(&node.Token, fmt.Sprintf("cannot mutate frozen %s", arr.Type()))
+	}
+
+	idx := key.(*object.Integer).Value
+	length := int64(array.Len())
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return newError(&node.Token, fmt.Sprintf("invalid argument: index %d out of bounds", key.(*object.Integer).Value))
+	}
+
+	array.Set(int(idx), val)
+	return val
+}
+
+func evalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := []object.SelectCase{}
+	arms := []*ast.SelectCase{}
+	var fallback *ast.SelectCase
+
+	for _, sc := range node.Cases {
+		if sc.Operation == nil {
+			fallback = sc
+			continue
+		}
+
+		args := evalExpressions(sc.Operation.Arguments, env)
+		if len(args) == 1 && args[0].Type() == object.ERROR_OBJ {
+			return args[0]
+		}
+
+		ch, ok := args[0].(*object.Channel)
+		if !ok {
+			return newError(&sc.Token, fmt.Sprintf("invalid argument: %s(%s) in select", sc.Operation.Function.String(), args[0].Type()))
+		}
+
+		c := object.SelectCase{Channel: ch}
+		if len(args) == 2 {
+			c.Value = args[1]
+		}
+		cases = append(cases, c)
+		arms = append(arms, sc)
+	}
+
+	chosen, val, ok, err := env.Runtime().Scheduler.Select(cases, fallback == nil)
+	if err != nil {
+		return newError(&node.Token, err.Error())
+	}
+
+	if chosen == -1 {
+		return Eval(fallback.Body, env)
+	}
+
+	arm := arms[chosen]
+	if arm.Name == nil {
+		return Eval(arm.Body, env)
+	}
+
+	armEnv := object.NewEnclosedEnvironment(env)
+	if !ok {
+		val = VOID
+	}
+	armEnv.Set(arm.Name.Value, val)
+	return Eval(arm.Body, armEnv)
+}
+
+func evalEnumLiteral(node *ast.EnumLiteral, env *object.Environment) object.Object {
+	names := []string{}
+	fields := [][]string{}
+	for _, v := range node.Variants {
+		names = append(names, v.Name.Value)
+
+		var fs []string
+		if v.Fields != nil {
+			fs = []string{}
+			for _, f := range v.Fields {
+				fs = append(fs, f.Value)
+			}
+		}
+		fields = append(fields, fs)
+	}
+
+	enum := object.NewEnum(node.Name.Value, names, fields)
+
+	idents := []*ast.Identifier{node.Name}
+	values := []object.Object{enum}
+	for i, c := range enum.Variants {
+		idents = append(idents, node.Variants[i].Name)
+		if c.Value != nil {
+			values = append(values, c.Value)
+		} else {
+			values = append(values, c)
+		}
+	}
+
+	for _, ident := range idents {
+		if _, declared := env.Get(ident.Value); declared {
+			return newError(&ident.Token, fmt.Sprintf("identifier already declared: %s", ident.Value))
+		}
+	}
+	for i, ident := range idents {
+		env.SetConst(ident.Value, values[i])
+	}
+
+	return enum
+}
+
+func evalCaseExpression(node *ast.CaseExpression, subject object.Object, env *object.Environment) object.Object {
+	variant, ok := subject.(*object.Variant)
+	if !ok {
+		return newError(&node.Token, fmt.Sprintf("case expects an enum variant, got %s", subject.Type()))
+	}
+	enum := variant.Constructor.Enum
+
+	for _, arm := range node.Arms {
+		if arm.IsWildcard() {
+			return Eval(arm.Body, env)
+		}
+
+		c, ok := enum.Variant(arm.Variant.Value)
+		if !ok {
+			return newError(&arm.Token, fmt.Sprintf("%s has no variant %s", enum.Name, arm.Variant.Value))
+		}
+		if c != variant.Constructor {
+			continue
+		}
+
+		if len(arm.Bindings) != len(c.Fields) {
+			return newError(&arm.Token, fmt.Sprintf("wrong number of bindings for %s: expected %d, found %d", c.Name, len(c.Fields), len(arm.Bindings)))
+		}
+
+		armEnv := object.NewEnclosedEnvironment(env)
+		for i, binding := range arm.Bindings {
+			if binding.Value != "_" {
+				armEnv.Set(binding.Value, variant.Values[i])
+			}
+		}
+		return Eval(arm.Body, armEnv)
+	}
+
+	return newError(&node.Token, fmt.Sprintf("unhandled variant in case: %s.%s", enum.Name, variant.Constructor.Name))
+}
+
+func evalClassLiteral(node *ast.ClassLiteral, env *object.Environment) object.Object {
+	if _, declared := env.Get(node.Name.Value); declared {
+		return newError(&node.Name.Token, fmt.Sprintf("identifier already declared: %s", node.Name.Value))
+	}
+
+	class := &object.Class{Name: node.Name.Value, Methods: map[string]*object.Function{}}
+
+	if node.Parent != nil {
+		parent := Eval(node.Parent, env)
+		if parent.Type() == object.ERROR_OBJ {
+			return parent
+		}
+		p, ok := parent.(*object.Class)
+		if !ok {
+			return newError(&node.Parent.Token, fmt.Sprintf("cannot inherit from %s", parent.Type()))
+		}
+		class.Parent = p
+	}
+
+	for _, m := range node.Methods {
+		fn := m.Function
+		class.Methods[m.Name.Value] = &object.Function{Name: m.Name.Value, Parameters: fn.Parameters, Body: fn.Body, Env: env, Generator: fn.Generator}
+	}
+
+	return env.SetConst(class.Name, class)
+}
+
+// construct creates an instance of class and initialises it with args.
+func construct(class *object.Class, args []object.Object, env *object.Environment, token *token.Token) object.Object {
+	instance := object.NewInstance(class)
+
+	init, ok := instance.Field("init")
+	if !ok {
+		if len(args) != 0 {
+			return newError(token, fmt.Sprintf("wrong number of arguments for %s: expected 0, found %d", class.Name, len(args)))
+		}
+		return instance
+	}
+
+	result := applyFunction(init, args, env, token)
+	if result.Type() == object.ERROR_OBJ {
+		return result
+	}
+	return instance
+}
+
+func evalFieldAssignExpression(node *ast.FieldAssignExpression, env *object.Environment) object.Object {
+	target := Eval(node.Target.Left, env)
+	if target.Type() == object.ERROR_OBJ {
+		return target
+	}
+
+	val := Eval(node.Value, env)
+	if val.Type() == object.ERROR_OBJ {
+		return val
+	}
+
+	if m, ok := target.(*object.Map); ok {
+		if m.Frozen {
+			return newError(&node.Token, fmt.Sprintf("cannot mutate frozen %s", target.Type()))
+		}
+		m.Set(node.Target.Name.Value, val)
+		return val
+	}
+
+	instance, ok := target.(*object.Instance)
+	if !ok {
+		return newError(&node.Token, fmt.Sprintf("invalid argument: %s.%s = %s", target.Type(), node.Target.Name.Value, val.Type()))
+	}
+	if instance.Frozen {
+		return newError(&node.Token, fmt.Sprintf("cannot mutate frozen %s", instance.Class.Name))
+	}
+
+	instance.SetField(node.Target.Name.Value, val)
+	return val
+}
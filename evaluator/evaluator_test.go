@@ -4,7 +4,15 @@ import (
 	"baboon/lexer"
 	"baboon/object"
 	"baboon/parser"
+	"baboon/token"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEvalVoid(t *testing.T) {
@@ -44,6 +52,56 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2.5", 2.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1.0 / 4.0 * 3.0 - 0.5", 0.25},
+		{"1.0 / 0.0", math.Inf(1)},
+		{"1.5 < 2.5", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"2.0 == 2.0", true},
+		{"1.5 + 1", "type mismatch: FLOAT + INTEGER"},
+		{"1 == 1.0", "type mismatch: INTEGER == FLOAT"},
+		{"!1.5", "unknown operator: !FLOAT"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case float64:
+			testFloatObject(t, i, evaluated, expected)
+		case bool:
+			testBooleanObject(t, i, evaluated, expected)
+		case string:
+			testErrorObject(t, i, evaluated, expected)
+		}
+	}
+
+	inspected := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1.0 / 3.0", "0.3333333333333333"},
+		{"1000000.0 * 1000000.0 * 1000000.0 * 1000.0", "1e+21"},
+		{"-1.0 / 0.0", "-Inf"},
+		{"[1.5, 2.0]", "[1.5, 2.0]"},
+	}
+
+	for i, tt := range inspected {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong inspect; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
 func TestEvalStringExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -219,6 +277,314 @@ func TestFunctionExpression(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclaration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"fn double(x) { x * 2 }; double(21)", 42},
+		{"double(21); fn double(x) { x * 2 }", VOID},
+		{"n :: double(21); fn double(x) { x * 2 }; n", 42},
+		{`
+fn is-even(n) { if n == 0 { return true }; is-odd(n - 1) }
+fn is-odd(n) { if n == 0 { return false }; is-even(n - 1) }
+is-even(10) == is-odd(11)
+`, true},
+		{"fn outer(x) { r :: inner(x); fn inner(y) { y + 1 }; r }; outer(1)", 2},
+		{"fn outer() { fn inner() { 1 } }; outer(); inner", "identifier not found: inner"},
+		{"fn f() { 1 }; f = 2", "assigning to const: f"},
+		{"f := 1; fn f() { 1 }", "identifier already declared: f"},
+		{"fn f() { 1 }; fn f() { 2 }", "identifier already declared: f"},
+		{"fn f(a, b) { a }; f(1)", "wrong number of arguments for f: expected 2, found 1"},
+		{"fn(a) { a }(1, 2)", "wrong number of arguments for fn: expected 1, found 2"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		default:
+			testVoidObject(t, i, eval)
+		}
+	}
+
+	inspected := testEval("fn add(a, b) { a + b }; add").Inspect()
+	if expected := "fn add(a, b) {\n(a + b)\n}"; inspected != expected {
+		t.Errorf("wrong inspect; expected %q, got %q", expected, inspected)
+	}
+
+	// the recursive call runs inside the generator of the outer one
+	counted := testEval("fn count(n) { if n > 0 { yield n; count(n - 1) } }; array(count(3))").Inspect()
+	if expected := "[3, 2, 1]"; counted != expected {
+		t.Errorf("wrong generated array; expected %q, got %q", expected, counted)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	// deferred calls record their order in log
+	prelude := "log := []; fn note(x) { log[0] = log[0] + x }; log = [\"\"]; "
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{prelude + `fn f() { defer note("a"); defer note("b"); note("c") }; f(); log[0]`, "cba"},
+		{prelude + `fn f(x) { defer note("d"); if x { return 1 }; note("e") }; f(true); log[0]`, "d"},
+		{prelude + `fn f() { defer note("outer"); g(); note("-") }; fn g() { defer note("inner") }; f(); log[0]`, "inner-outer"},
+		{prelude + `fn f() { [fn() { defer note("x") }() for i in 0..3]; note("-") }; f(); log[0]`, "xxx-"},
+		{prelude + `fn f() { defer note(x); x := "late" }; f(); log[0]`, "late"},
+		{prelude + `fn gen() { defer note("done"); yield 1; yield 2 }; array(gen()); log[0]`, "done"},
+		{`fn f() { defer 1; 2 }; f()`, 2},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			testStringObject(t, i, eval, expected)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"fn f() { defer -true; 1 }; f()", "[1:16] unknown operator: -BOOLEAN"},
+		{"fn f() { defer -true; -false }; f()", "[1:23] unknown operator: -BOOLEAN\ndeferred: [1:16] unknown operator: -BOOLEAN"},
+		{"fn f() { defer 1 + \"a\"; defer -true; 1 }; f()", "[1:31] unknown operator: -BOOLEAN\ndeferred: [1:18] type mismatch: INTEGER + STRING"},
+	}
+
+	for i, tt := range errors {
+		eval := testEval(tt.input)
+		if eval.Type() != object.ERROR_OBJ || eval.Inspect() != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, eval.Inspect())
+		}
+	}
+}
+
+func TestMethodCall(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"héllo".len()`, 5},
+		{"[1, 2, 3].tail().first()", 2},
+		{"fn sum(xs) { if len(xs) == 0 { return 0 }; xs.first() + xs.tail().sum() }; [1, 2, 3].sum()", 6},
+		{"fn add(a, b) { a + b }; 1.add(2)", 3},
+		{"add :: fn(a, b) { a + b }; 1.add(2).add(3)", 6},
+		{"(0..10 by 2).len()", 5},
+		{"-[1, 2].len()", -2},
+		{"enum Shape { Rect(w, h) }; Rect(2, 3).h", 3},
+		{"enum Shape { Rect(w, h) }; r :: Rect(2, 3); r.w * r.h", 6},
+		{"enum Box { Box(get) }; b :: Box(fn() { 7 }); b.get()", 7},
+		{"enum Box { Box(len) }; Box(fn() { 8 }).len()", 8},
+		{"enum Shape { Circle(r), Empty }; Shape.Circle(4).r", 4},
+		{"enum Shape { Circle(r), Empty }; Shape.Empty == Empty", true},
+		{"1.nope()", "INTEGER has no field nope and identifier not found: nope"},
+		{"1.nope", "INTEGER has no field nope"},
+		{"enum Shape { Circle(r) }; Circle(1).d", "VARIANT has no field d"},
+		{"x :: 5; 1.x()", "not a function: INTEGER"},
+		{`"a".len(1)`, "too many arguments for len: expected 1, found 2"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+}
+
+func TestClass(t *testing.T) {
+	classes := `
+class Point {
+	fn init(x, y) { self.x = x; self.y = y }
+	fn norm() { self.x * self.x + self.y * self.y }
+	fn move(dx) { self.x = self.x + dx; self }
+}
+class Point3 < Point {
+	fn init(x, y, z) { super.init(x, y); self.z = z }
+	fn norm() { super.norm() + self.z * self.z }
+}
+class Empty { }
+`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{classes + "Point(3, 4).norm()", 25},
+		{classes + "Point(3, 4).move(1).x", 4},
+		{classes + "p :: Point(3, 4); p.y", 4},
+		{classes + "Point3(1, 2, 3).norm()", 14},
+		{classes + "Point3(1, 2, 3).move(1).norm()", 17},
+		{classes + "norm :: Point(3, 4).norm; norm()", 25},
+		{classes + "p := Point(1, 1); f :: p.move; f(2); p.x", 3},
+		{classes + "Point(1, 2) == Point(1, 2)", true},
+		{classes + "Point(1, 2) == Point(2, 1)", false},
+		{classes + "Point(1, 2) != Point3(1, 2, 3)", true},
+		{classes + "Empty() == Empty()", true},
+		{classes + "a := Empty(); a.next = a; b := Empty(); b.next = b; a == b", true},
+		{classes + "a := Empty(); a.next = a; b := Empty(); b.next = Empty(); a == b", false},
+		{classes + "a := Empty(); b := Empty(); a.next = b; b.next = a; c := Empty(); c.next = c; a == c", true},
+		{classes + "instanceof(Point3(1, 2, 3), Point)", true},
+		{classes + "Point(1, 2).instanceof(Point3)", false},
+		{classes + "instanceof(1, Point)", false},
+		{"enum Shape { Circle(r) }; Circle(1).instanceof(Shape)", true},
+		{classes + "Point(1)", "wrong number of arguments for init: expected 2, found 1"},
+		{classes + "Empty(1)", "wrong number of arguments for Empty: expected 0, found 1"},
+		{classes + "Point(1, 2).z", "INSTANCE has no field z"},
+		{classes + "p :: Point(1, 2); p.move(1)", "cannot mutate frozen Point"},
+		{classes + "class Point { }", "identifier already declared: Point"},
+		{"class A < B { }", "identifier not found: B"},
+		{"B :: 1; class A < B { }", "cannot inherit from INTEGER"},
+		{"x := 1; x.y = 2", "invalid argument: INTEGER.y = INTEGER"},
+		{"instanceof(1, 2)", "invalid argument: instanceof(INTEGER, INTEGER)"},
+		{"class A { fn f() { super.f() } }; A().f()", "identifier not found: super"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+
+	inspected := []struct {
+		input    string
+		expected string
+	}{
+		{classes + "Point3(1, 2, 3)", "Point3(x: 1, y: 2, z: 3)"},
+		{classes + "Empty()", "Empty()"},
+		{classes + "a := Empty(); a.next = a; a", "Empty(next: <cycle>)"},
+		{classes + "a := Empty(); a.all = [a, Empty(), a]; a", "Empty(all: [<cycle>, Empty(), <cycle>])"},
+		{classes + "a := Empty(); b := Empty(); a.b = b; b.a = a; [a, b]", "[Empty(b: Empty(a: <cycle>)), Empty(a: Empty(b: <cycle>))]"},
+		{classes + "Point3", "class Point3 < Point"},
+		{classes + "Point", "class Point"},
+	}
+
+	for i, tt := range inspected {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong inspect; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestProtocols(t *testing.T) {
+	stack := `
+fn __len() { len(self.value) };
+Stack :: type("Stack", [
+	__len,
+	["__eq", fn(other) { len(self) == len(other) }],
+	["__iter", fn() { self.value }],
+	["__call", fn(i) { self.value[i] }],
+	["push", fn(x) { Stack(append(self.value, x)) }]
+]);
+s :: Stack([1, 2, 3]);
+`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{stack + "len(s)", 3},
+		{stack + "s.len()", 3},
+		{stack + "s.push(4).len()", 4},
+		{stack + "s(1)", 2},
+		{stack + "s == Stack([4, 5, 6])", true},
+		{stack + "s != Stack([4, 5, 6])", false},
+		{stack + "s == Stack([])", false},
+		{stack + "s in [Stack([4, 5, 6])]", true},
+		{stack + "len([x for x in s if x > 1])", 2},
+		{stack + "array(s)[2]", 3},
+		{stack + "s.value == s.value", true},
+		{"Box :: type(\"Box\", []); len(Box(1))", "invalid argument: len(TYPED)"},
+		{"Box :: type(\"Box\", []); Box(1)()", "not a function: TYPED"},
+		{"Box :: type(\"Box\", []); Box(1) == Box(1)", false},
+		{"Box :: type(\"Box\", []); [x for x in Box(1)]", "invalid argument: cannot iterate over TYPED"},
+		{"Box :: type(\"Box\", [[\"__len\", fn() { \"a\" }]]); len(Box(1))", "__len must return INTEGER, found STRING"},
+		{"Box :: type(\"Box\", [[\"__eq\", fn(o) { 1 }]]); Box(1) != Box(1)", "__eq must return BOOLEAN, found INTEGER"},
+		{"Box :: type(\"Box\", [[\"__iter\", fn() { 1 }]]); array(Box(1))", "__iter must return an iterable, found INTEGER"},
+		{"Box :: type(\"Box\", [[\"__call\", fn() { 1 }]]); Box(1)(2)", "wrong number of arguments for fn: expected 0, found 1"},
+		{"Box :: type(\"Box\", []); Box()", "wrong number of arguments for Box: expected 1, found 0"},
+		{"type(\"Box\", [1])", "invalid method for Box: 1"},
+		{"type(\"Box\", [[\"f\", 1]])", "invalid method for Box: [\"f\", 1]"},
+		{"type(\"Box\", [fn() { 1 }])", "invalid method for Box: fn() {\n1\n}"},
+		{"type(1, [])", "invalid argument: type(INTEGER, ARRAY)"},
+		{"class C { fn init(n) { self.n = n }; fn __len() { self.n }; fn __call(x) { x + self.n } }; len(C(4)) + C(1)(2)", 7},
+		{"class C { fn __eq(o) { true } }; C() == 1", true},
+		{"class C { fn __eq(o) { true } }; 1 == C()", true},
+		{"class C { fn __eq(o) { true } }; 1 != C()", false},
+		{"class C { fn __eq(o) { true } }; class D {}; D() == C()", true},
+		{"Box :: type(\"Box\", [[\"__eq\", fn(o) { o == self.value }]]); Box(1) == 1", true},
+		{"Box :: type(\"Box\", [[\"__eq\", fn(o) { o == self.value }]]); 1 == Box(1)", true},
+		{"Box :: type(\"Box\", [[\"__eq\", fn(o) { o == self.value }]]); 2 == Box(1)", false},
+		{"class C { fn __iter() { 0..3 } }; [i for i in C()][2]", 2},
+		{"class C { fn __iter() { 0..3 } }; 2 in C()", true},
+		{"class C { fn __iter() { 0..3 } }; 3 in C()", false},
+		{"class C { fn __iter() { fn() { yield 1; yield -true }() } }; 1 in C()", true},
+		{"class C { fn __iter() { fn() { yield 1; yield -true }() } }; 2 in C()", "unknown operator: -BOOLEAN"},
+		{"class C { fn __iter() { 1 } }; 1 in C()", "__iter must return an iterable, found INTEGER"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+
+	displayed := []struct {
+		input    string
+		expected string
+	}{
+		{"Box :: type(\"Box\", []); Box(1)", "Box(1)"},
+		{"Box :: type(\"Box\", [[\"__str\", fn() { \"box\" }]]); Box(1)", "box"},
+		{"class C { fn __str() { \"c\" } }; C()", "c"},
+		{"\"s\"", "s"},
+		{"[\"s\"]", "[\"s\"]"},
+		{"class C { fn __str() { \"c\" } }; [C(), {\"c\": C()}, \"s\"]", "[c, {\"c\": c}, \"s\"]"},
+		{"type(\"Box\", [])", "type Box"},
+	}
+
+	for i, tt := range displayed {
+		res := display(testEval(tt.input), &token.Token{})
+		testStringObject(t, i, res, tt.expected)
+	}
+
+	res := display(testEval("Box :: type(\"Box\", [[\"__str\", fn() { 1 }]]); Box(1)"), &token.Token{})
+	testErrorObject(t, 0, res, "__str must return STRING, found INTEGER")
+	res = display(testEval("Box :: type(\"Box\", [[\"__str\", fn() { 1 }]]); [Box(1)]"), &token.Token{})
+	testErrorObject(t, 1, res, "__str must return STRING, found INTEGER")
+}
+
 func TestCallExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -245,9 +611,9 @@ func TestBuiltin(t *testing.T) {
 		expected interface{}
 	}{
 		{`len("123456")`, 6},
-		{`len("৩")`, 3},
+		{`len("৩")`, 1},
 		{`len("")`, 0},
-		{`len("šíleně žluťoučký ৩æ")`, 29},
+		{`len("šíleně žluťoučký ৩æ")`, 19},
 		{`len(42)`, "invalid argument: len(INTEGER)"},
 		{`len("abc", "def")`, "too many arguments for len: expected 1, found 2"},
 		{`len()`, "not enough arguments for len: expected 1, found 0"},
@@ -292,48 +658,242 @@ sum(map(ns, fn(n) {n * 2}))
 	}
 }
 
-func TestArrayLiteral(t *testing.T) {
+func TestUnicodeString(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected []int
+		expected interface{}
 	}{
-		{`[0, 1, 2]`, []int{0, 1, 2}},
-		{`[]`, []int{}},
+		{`first("ěšč")`, "ě"},
+		{`tail("ěšč")`, "šč"},
+		{`tail("")`, ""},
+		{`first("")`, "invalid argument for first: index 0 out of bounds"},
+		{`"žluťoučký"[3]`, "ť"},
+		{`"žluťoučký"[-1]`, "ý"},
+		{`"abc"[3]`, "invalid argument: index 3 out of bounds"},
+		{`len(bytes("ěšč"))`, 6},
+		{`len(runes("ěšč"))`, 3},
+		{`runes("ě")[0]`, 283},
+		{`bytes(bytes("ěšč"))`, "ěšč"},
+		{`runes(runes("šíleně"))`, "šíleně"},
+		{`bytes([256])`, "invalid argument for bytes: item 0 is not a byte"},
+		{`runes(1)`, "invalid argument: runes(INTEGER)"},
+		{`len(graphemes("é"))`, 1},
+		{`len(graphemes("🇨🇿🇸🇰"))`, 2},
+		{`len(graphemes("👍🏽ok"))`, 3},
+		{`graphemes("👨‍👩‍👧x")[0]`, "👨‍👩‍👧"},
 	}
 
 	for i, tt := range tests {
 		eval := testEval(tt.input)
 
-		array, ok := eval.(*object.ArrayLiteral)
-		if !ok {
-			t.Errorf("[%d] object is not Array, got %T", i, eval)
-			continue
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			if _, ok := eval.(*object.Error); ok {
+				testErrorObject(t, i, eval, expected)
+			} else {
+				testStringObject(t, i, eval, expected)
+			}
 		}
+	}
+}
 
-		for j, expected := range tt.expected {
-			item, ok := array.Items[j].(*object.Integer)
-			if !ok {
-				t.Errorf("[%d] array item %d is not Integer, got %s", i, j, array.Items[j].Type())
-				continue
-			}
+func TestStringLibrary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len(split("a,b,,c", ","))`, 4},
+		{`split("a,b,,c", ",")[2]`, ""},
+		{`split("a, b", ", ").join("|")`, "a|b"},
+		{`split("ab", "").join("|")`, "a|b"},
+		{`join([], ",")`, ""},
+		{`join(["a", 1], ",")`, "invalid argument for join: item 1 is not a string"},
+		{`join("ab", ",")`, "invalid argument: join(STRING, STRING)"},
+		{`split("a")`, "wrong number of arguments for split: expected 2, found 1"},
+		{`trim("  ab c	 ")`, "ab c"},
+		{`upper("šíp")`, "ŠÍP"},
+		{`"ŠÍP".lower()`, "šíp"},
+		{`upper(1)`, "invalid argument: upper(INTEGER)"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a-b", "-")`, "wrong number of arguments for replace: expected 3, found 2"},
+		{`contains("baboon", "boo")`, true},
+		{`"baboon".contains("x")`, false},
+		{`starts_with("baboon", "ba")`, true},
+		{`ends_with("baboon", "ba")`, false},
+		{`contains("a", 1)`, "invalid argument: contains(STRING, INTEGER)"},
+		{`index_of("žluťoučký", "ou")`, 4},
+		{`index_of("abc", "x")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`repeat("ab", -1)`, "invalid argument for repeat: negative count -1"},
+		{`repeat("ab", 4611686018427387904)`, "invalid argument for repeat: result longer than 268435456 bytes"},
+		{`repeat("", 9223372036854775807)`, ""},
+		{`len(repeat("ab", 134217728))`, 268435456},
+		{`pad_left("7", 3, "0")`, "007"},
+		{`pad_left("ž", 3)`, "  ž"},
+		{`pad_right("ab", 5, "xy")`, "abxyx"},
+		{`pad_right("abc", 2)`, "abc"},
+		{`pad_right("a", 6, "xyž")`, "axyžxy"},
+		{`pad_left("a", 9223372036854775807)`, "invalid argument for pad_left: result longer than 268435456 bytes"},
+		{`pad_right("a", 134217729, "ž")`, "invalid argument for pad_right: result longer than 268435456 bytes"},
+		{`len(pad_right("", 134217728, "ž"))`, 134217728},
+		{`pad_left("a", 3, "")`, "invalid argument for pad_left: fill cannot be empty"},
+		{`pad_left("a")`, "wrong number of arguments for pad_left: expected 2 or 3, found 1"},
+		{`pad_right("a", "b")`, "invalid argument: pad_right(STRING, STRING)"},
+		{`len(chars("ěšč"))`, 3},
+		{`chars("ěšč")[1]`, "š"},
+		{`len(chars(""))`, 0},
+	}
 
-			if item.Value != int64(expected) {
-				t.Errorf("[%d] array item %d has wrong value; expected %d, got %d", i, j, expected, item.Value)
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			if _, ok := eval.(*object.Error); ok {
+				testErrorObject(t, i, eval, expected)
+			} else {
+				testStringObject(t, i, eval, expected)
 			}
 		}
 	}
 }
 
-func TestArrayAccess(t *testing.T) {
+func TestArrayLibrary(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"(1..=3).map(fn(x) { x * x })", "[1, 4, 9]"},
+		{`"ab".map(upper)`, `["A", "B"]`},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", "[3, 4]"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x })", "6"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", "16"},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", "0"},
+		{"each(1..4, fn(x) { x })", "<void>"},
+		{`each([1, "a"], fn(x) { x + 1 })`, "[1:26] type mismatch: STRING + INTEGER"},
+		{"any([1, 2, 3], fn(x) { x > 2 })", "true"},
+		{"any([], fn(x) { x > 2 })", "false"},
+		{"all([1, 2, 3], fn(x) { x > 0 })", "true"},
+		{"all([1, 2, 3], fn(x) { x > 1 })", "false"},
+		{"find([1, 2, 3], fn(x) { x > 1 })", "2"},
+		{"find([1, 2, 3], fn(x) { x > 3 })", "<void>"},
+		{`zip([1, 2, 3], "ab")`, `[[1, "a"], [2, "b"]]`},
+		{"zip(0..2, [true, false], [3, 4])", "[[0, true, 3], [1, false, 4]]"},
+		{`enumerate(["a", "b"])`, `[[0, "a"], [1, "b"]]`},
+		{"flatten([[1, 2], 3, [[4]], []])", "[1, 2, 3, [4]]"},
+		{"reverse([1, 2, 3])", "[3, 2, 1]"},
+		{"reverse(0..3)", "[2, 1, 0]"},
+		{`reverse("žluť")`, `"ťulž"`},
+		{"sort([3, 1, 2])", "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, `["a", "b", "c"]`},
+		{"sort([3, 1, 2], fn(a, b) { a > b })", "[3, 2, 1]"},
+		{"sort([[2, 0], [1, 1], [2, 2], [1, 3]], fn(a, b) { a[0] < b[0] })", "[[1, 1], [1, 3], [2, 0], [2, 2]]"},
+		{`sort_by(["ccc", "a", "bb", "d"], len)`, `["a", "d", "bb", "ccc"]`},
+		{"xs :: [2, 1]; sort(xs); xs", "[2, 1]"},
+		{"fn gen() { yield 1; yield 2; yield 3 }; find(gen(), fn(x) { x > 1 })", "2"},
+		{"fn sum(xs) { reduce(xs, fn(a, x) { a + x }, 0) }; sum(map(1..=100, fn(x) { x * 2 }))", "10100"},
+		{"map([1], fn() { 1 })", "[1:4] wrong number of arguments for fn: expected 0, found 1"},
+		{"map([1], 1)", "[1:4] not a function: INTEGER"},
+		{"map(1, fn(x) { x })", "[1:4] invalid argument for map: cannot iterate over INTEGER"},
+		{"map([1])", "[1:4] wrong number of arguments for map: expected 2, found 1"},
+		{"filter([1], fn(x) { x })", "[1:7] invalid result for filter: expected BOOLEAN, found INTEGER"},
+		{"reduce([], fn(a, x) { a })", "[1:7] invalid argument for reduce: empty sequence and no initial value"},
+		{"zip([1])", "[1:4] not enough arguments for zip: expected 2, found 1"},
+		{`sort([1, "a"])`, "[1:5] invalid argument for sort: cannot compare STRING and INTEGER"},
+		{"sort([1, 2], fn(a, b) { 1 })", "[1:5] invalid result for sort: expected BOOLEAN, found INTEGER"},
+		{"flatten(1)", "[1:8] invalid argument: flatten(INTEGER)"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestMathLibrary(t *testing.T) {
 	tests := []struct {
 		input    string
 		expected interface{}
 	}{
-		{`[0, 1, 2, 3][2]`, 2},
-		{`[42, "abc", true][1]`, "abc"},
-		{`arr :: [true, false]; arr[0]`, true},
-		{`foo :: [fn(bar) { len(bar) }]; foo[0]("12345")`, 5},
-		{`[3, 2, 1][-2]`, 2},
+		{"abs(-3)", 3},
+		{"abs(3)", 3},
+		{"abs(-9223372036854775807 - 1)", "integer overflow: abs(-9223372036854775808)"},
+		{"min(3, 1, 2)", 1},
+		{"max(3, 1, 2)", 3},
+		{"max([4, 9, 2])", 9},
+		{"min(5..10)", 5},
+		{"max(7)", "invalid argument for max: cannot iterate over INTEGER"},
+		{"min([])", "invalid argument for min: empty sequence"},
+		{`max(1, "a")`, "invalid argument for max: cannot compare STRING and INTEGER"},
+		{"max()", "not enough arguments for max: expected 1, found 0"},
+		{"clamp(15, 0, 10)", 10},
+		{"clamp(-5, 0, 10)", 0},
+		{"clamp(5, 0, 10)", 5},
+		{"clamp(5, 10, 0)", "domain error: clamp(5, 10, 0)"},
+		{"pow(2, 10)", 1024},
+		{"pow(-3, 3)", -27},
+		{"pow(0, 0)", 1},
+		{"pow(2, 62)", 4611686018427387904},
+		{"pow(2, 63)", "integer overflow: pow(2, 63)"},
+		{"pow(-2, 63)", -9223372036854775807 - 1},
+		{"pow(2, -1)", "domain error: pow(2, -1)"},
+		{"sqrt(16)", 4},
+		{"sqrt(17)", 4},
+		{"sqrt(9223372036854775807)", 3037000499},
+		{"sqrt(-1)", "domain error: sqrt(-1)"},
+		{"abs(-1.5)", 1.5},
+		{"pow(2.0, 0.5)", math.Sqrt2},
+		{"pow(0.0, -1.0)", "domain error: pow(0.0, -1.0)"},
+		{"pow(2.0, 3)", "invalid argument: pow(FLOAT, INTEGER)"},
+		{"sqrt(2.0)", math.Sqrt2},
+		{"sqrt(-1.0)", "domain error: sqrt(-1.0)"},
+		{"floor(2.5) + ceil(2.5) + round(2.5)", 8},
+		{"floor(-2.5) + ceil(-2.5) + round(-2.5)", -8},
+		{"floor(3) + ceil(3) + round(3)", 9},
+		{"round(1.0 / 0.0)", "domain error: round(+Inf)"},
+		{"floor(10000000000000000000.0)", "integer overflow: floor(1e+19)"},
+		{"sin(0)", 0.0},
+		{"cos(0)", 1.0},
+		{"sin(2)", math.Sin(2)},
+		{"sin(PI / 2.0)", 1.0},
+		{"atan(1.0) * 4.0", math.Pi},
+		{"asin(2)", "domain error: asin(2)"},
+		{"acos(-1)", math.Pi},
+		{"ln(E)", 1.0},
+		{"ln(20)", math.Log(20)},
+		{"ln(0)", "domain error: ln(0)"},
+		{"float(3)", 3.0},
+		{"float(3) / float(4)", 0.75},
+		{"min(2.5, 1.5)", 1.5},
+		{"log(1000, 10)", 3},
+		{"log(1023, 2)", 9},
+		{"log(1, 2)", 0},
+		{"log(8, 1)", "domain error: log(8, 1)"},
+		{"log(-8, 2)", "domain error: log(-8, 2)"},
+		{"gcd(12, -18)", 6},
+		{"gcd(0, 0)", 0},
+		{"lcm(4, 6)", 12},
+		{"lcm(-4, 6)", 12},
+		{"lcm(0, 6)", 0},
+		{"lcm(9223372036854775807, 2)", "integer overflow: lcm(9223372036854775807, 2)"},
+		{"PI", math.Pi},
+		{"E", math.E},
+		{"PI :: 4; PI", 4},
+		{"2.pow(5)", 32},
+		{`abs("a")`, "invalid argument: abs(STRING)"},
+		{"pow(2)", "wrong number of arguments for pow: expected 2, found 1"},
+		{"sin(1, 2)", "wrong number of arguments for sin: expected 1, found 2"},
+		{`cos("a")`, "invalid argument: cos(STRING)"},
+		{`floor("a")`, "invalid argument: floor(STRING)"},
 	}
 
 	for i, tt := range tests {
@@ -342,52 +902,1086 @@ func TestArrayAccess(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, i, eval, int64(expected))
+		case float64:
+			testFloatObject(t, i, eval, expected)
 		case string:
-			testStringObject(t, i, eval, expected)
-		case bool:
-			testBooleanObject(t, i, eval, expected)
+			testErrorObject(t, i, eval, expected)
 		}
 	}
 }
 
-func TestAssignment(t *testing.T) {
+func TestMap(t *testing.T) {
 	tests := []struct {
 		input    string
-		expected int64
+		expected string
 	}{
-		{"a :: 1", 1},
-		{"a := 2", 2},
-		{"a :: b := 3; a + b", 6},
-		{"a := 4; a = 5", 5},
+		{`m :: {}; m`, "{}"},
+		{`m :: {"b": 1, "a": [2], "b": 3}; m`, `{"b": 3, "a": [2]}`},
+		{`k :: "x"; m :: {k: 1}; m["x"]`, "1"},
+		{`m :: {"a": 1}; m.a + m["a"]`, "2"},
+		{`m :: {"a": {"b": 2}}; m.a.b`, "2"},
+		{`m := {}; m["x"] = 1; m.y = 2; m`, `{"x": 1, "y": 2}`},
+		{`m := {"a": 1}; m["a"] = 2; m`, `{"a": 2}`},
+		{`m := {}; m.a = m; m`, `{"a": <cycle>}`},
+		{`m := {}; n := {"m": m}; m.n = n; m.all = [m, n]; m`, `{"n": {"m": <cycle>}, "all": [<cycle>, {"m": <cycle>}]}`},
+		{`fn() { {"a": 1} }()`, `{"a": 1}`},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`"a" in {"a": 1}`, "true"},
+		{`"b" in {"a": 1}`, "false"},
+		{`keys({"b": 1, "a": 2})`, `["b", "a"]`},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`[k + "!" for k in {"b": 1, "a": 2}]`, `["b!", "a!"]`},
+		{`m :: {"a": 1}; m["b"]`, `[1:17] key not found: "b"`},
+		{`m :: {"a": 1}; m.b`, "[1:17] MAP has no field b"},
+		{`m :: {1: 2}`, "[1:6] invalid map key: INTEGER"},
+		{`m :: {"a": 1}; m["a"] = 2`, "[1:23] cannot mutate frozen MAP"},
+		{`m :: {"a": 1}; m.b = 2`, "[1:20] cannot mutate frozen MAP"},
+		{`m :: {"a": {}}; m.a["b"] = 2`, "[1:26] cannot mutate frozen MAP"},
+		{`keys([])`, "[1:5] invalid argument: keys(ARRAY)"},
 	}
 
 	for i, tt := range tests {
-		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
 	}
 }
 
-/* HELPERS */
+func TestJSON(t *testing.T) {
+	parsed := []struct {
+		input    string
+		expected string
+	}{
+		{`{"name": "baboon", "tags": ["a", "b"], "n": -12, "ok": true, "none": null, "nested": {"x": []}}`,
+			`{"name": "baboon", "tags": ["a", "b"], "n": -12, "ok": true, "none": <void>, "nested": {"x": []}}`},
+		{` [1, 0, -0] `, "[1, 0, 0]"},
+		{`"a\"b\\c\/\u00e9\ud83d\ude00\n"`, "\"a\"b\\c/é😀\n\""},
+		{`"\ud83dx\ud83d\u0041\ude00\ud83d\ud83d\ude00"`, "\"\ufffdx\ufffdA\ufffd\ufffd😀\""},
+		{`{"a": 1, "a": 2}`, `{"a": 2}`},
+		{`{"a": 1,}`, "[0:0] invalid JSON at 1:9: unexpected character '}'"},
+		{"{\n  \"a\": [1, 2\n  }", "[0:0] invalid JSON at 3:3: unexpected character '}'"},
+		{`[1, 2`, "[0:0] invalid JSON at 1:6: unexpected end of input"},
+		{``, "[0:0] invalid JSON at 1:1: unexpected end of input"},
+		{`[1.5, -0.25, 1e3, 2E-2, 1.5e+1, -0.0]`, "[1.5, -0.25, 1000.0, 0.02, 15.0, -0.0]"},
+		{`[1.]`, "[0:0] invalid JSON at 1:4: unexpected character ']'"},
+		{`[.5]`, "[0:0] invalid JSON at 1:2: unexpected character '.'"},
+		{`[1e+]`, "[0:0] invalid JSON at 1:5: unexpected character ']'"},
+		{`1e400`, "[0:0] invalid JSON at 1:1: number out of range: 1e400"},
+		{`01`, "[0:0] invalid JSON at 1:2: unexpected character '1'"},
+		{`99999999999999999999`, "[0:0] invalid JSON at 1:1: integer out of range: 99999999999999999999"},
+		{`"žluť\x"`, "[0:0] invalid JSON at 1:7: unexpected character 'x'"},
+		{"\"a\nb\"", "[0:0] invalid JSON at 1:3: unexpected character '\\n'"},
+		{`[tru]`, "[0:0] invalid JSON at 1:2: unexpected character 't'"},
+		{`{1: 2}`, "[0:0] invalid JSON at 1:2: unexpected character '1'"},
+		{`null x`, "[0:0] invalid JSON at 1:6: unexpected character 'x'"},
+	}
 
-func testEval(input string) object.Object {
-	l := lexer.New(input)
-	p := parser.New(l)
-	program := p.ParseProgram()
-	if len(p.Errors()) != 0 {
-		return &object.Error{Message: "program could not be parsed"}
+	parse := builtins["json_parse"].Fn
+	for i, tt := range parsed {
+		res := parse(nil, &token.Token{}, &object.String{Value: tt.input})
+		if res.Inspect() != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, res.Inspect())
+		}
 	}
-	env := object.NewEnvironment()
-	return Eval(program, env)
-}
 
-func testIntegerObject(t *testing.T, i int, obj object.Object, expected int64) bool {
-	result, ok := obj.(*object.Integer)
-	if !ok {
-		t.Errorf("[%d] object is not Integer, got %T", i, obj)
-		return false
+	nested := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	if res := parse(nil, &token.Token{}, &object.String{Value: nested}); res.Type() != object.ARRAY_OBJ {
+		t.Errorf("wrong result for deep nesting; got %s", res.Type())
+	}
+	deep := []struct {
+		input    string
+		expected string
+	}{
+		{strings.Repeat("[", 20000000), "[0:0] invalid JSON at 1:10001: nesting too deep"},
+		{strings.Repeat(`{"a": `, 10001), "[0:0] invalid JSON at 1:60001: nesting too deep"},
+	}
+	for i, tt := range deep {
+		if res := parse(nil, &token.Token{}, &object.String{Value: tt.input}); res.Inspect() != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, res.Inspect())
+		}
 	}
 
-	if result.Value != expected {
-		t.Errorf("[%d] object has wrong value, expected %d, got %d", i, expected, result.Value)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("[1, true, null]")`, "[1, true, <void>]"},
+		{`json_parse(1)`, "[1:11] invalid argument: json_parse(INTEGER)"},
+		{`json_parse("[1,")`, "[1:11] invalid JSON at 1:4: unexpected end of input"},
+		{`json_stringify({"a": 1, "b": [true, "x"], "c": {}, "d": []})`, `"{"a":1,"b":[true,"x"],"c":{},"d":[]}"`},
+		{`json_stringify(json_parse("[]"))`, `"[]"`},
+		{`json_stringify([1.5, 2.0, -0.25, 1.0 / 3.0])`, `"[1.5,2.0,-0.25,0.3333333333333333]"`},
+		{`json_stringify([1.0 / 0.0])`, "[1:15] invalid argument for json_stringify: cannot serialise +Inf"},
+		{`json_stringify(print)`, "[1:15] invalid argument for json_stringify: cannot serialise BUILTIN"},
+		{`json_stringify({"f": fn() { 1 }})`, "[1:15] invalid argument for json_stringify: cannot serialise FUNCTION"},
+		{`m := {}; m["m"] = m; json_stringify(m)`, "[1:36] invalid argument for json_stringify: cycle in MAP"},
+		{`xs := [1]; xs[0] = [xs]; json_stringify(xs)`, "[1:40] invalid argument for json_stringify: cycle in ARRAY"},
+		{`xs :: [1]; json_stringify([xs, xs])`, `"[[1],[1]]"`},
+		{`json_stringify(1, -1)`, "[1:15] invalid argument for json_stringify: negative indent -1"},
+		{`json_stringify(1, 9223372036854775807)`, "[1:15] invalid argument for json_stringify: indent 9223372036854775807 is greater than 10"},
+		{`json_stringify([1], 10).len()`, "15"},
+		{`json_stringify(1, true)`, "[1:15] invalid argument: json_stringify(INTEGER, BOOLEAN)"},
+		{`json_stringify()`, "[1:15] wrong number of arguments for json_stringify: expected 1 or 2, found 0"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	pretty := []struct {
+		input    string
+		expected string
+	}{
+		{`json_stringify({"a": [1, {}], "b": {"c": "d"}}, 2)`, "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": {\n    \"c\": \"d\"\n  }\n}"},
+		{`json_stringify([1], "\t")`, "[\n\\t1\n]"},
+		{`json_stringify([], 2)`, "[]"},
+		{`json_stringify(json_parse("[1, 2]"), 0)`, "[\n1,\n2\n]"},
+	}
+
+	for i, tt := range pretty {
+		testStringObject(t, i, testEval(tt.input), tt.expected)
+	}
+
+	escaped := builtins["json_stringify"].Fn(nil, &token.Token{}, &object.String{Value: "a\"b\\\n\x01é"})
+	testStringObject(t, 0, escaped, `"a\"b\\\n\u0001é"`)
+}
+
+func TestFileIO(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "new"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	env := object.NewEnvironment()
+	env.Runtime().Root = root
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`write_file("a.txt", "hello")`, "<void>"},
+		{`read_file("a.txt")`, `"hello"`},
+		{`append_file("a.txt", " world"); read_file("a.txt")`, `"hello world"`},
+		{`append_file("b.txt", "new"); read_file("./b.txt")`, `"new"`},
+		{`exists("a.txt")`, "true"},
+		{`exists("nope")`, "false"},
+		{`mkdir("d/e"); exists("d/e")`, "true"},
+		{`write_file("d/e/../f.txt", ""); list_dir("d")`, `["e", "f.txt"]`},
+		{`list_dir(".")`, `["a.txt", "b.txt", "d", "dangling", "link"]`},
+		{`remove("d/e"); remove("b.txt"); list_dir("d")`, `["f.txt"]`},
+		{`remove("d")`, "[1:7] remove failed: d: directory not empty"},
+		{`remove(".")`, "[1:7] remove failed: .: path is the allowed directory itself"},
+		{`remove("d/..")`, "[1:7] remove failed: d/..: path is the allowed directory itself"},
+		{`read_file("nope")`, "[1:10] read_file failed: nope: no such file or directory"},
+		{`list_dir("a.txt")`, "[1:9] list_dir failed: a.txt: not a directory"},
+		{`read_file("../x")`, "[1:10] read_file failed: ../x: path outside of the allowed directory"},
+		{`exists("d/../../x")`, "[1:7] exists failed: d/../../x: path outside of the allowed directory"},
+		{`read_file("` + filepath.Join(outside, "secret") + `")`, "[1:10] read_file failed: " + filepath.Join(outside, "secret") + ": path outside of the allowed directory"},
+		{`read_file("link/secret")`, "[1:10] read_file failed: link/secret: path outside of the allowed directory"},
+		{`write_file("dangling", "x")`, "[1:11] write_file failed: dangling: path outside of the allowed directory"},
+		{`write_file("a.txt")`, "[1:11] wrong number of arguments for write_file: expected 2, found 1"},
+		{`write_file("a.txt", 1)`, "[1:11] invalid argument: write_file(STRING, INTEGER)"},
+	}
+
+	for i, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		if actual := Eval(program, env).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "new")); err == nil {
+		t.Errorf("file created outside of the root")
+	}
+
+	// an empty root could be removed like any other directory
+	empty := object.NewEnvironment()
+	empty.Runtime().Root = t.TempDir()
+	for i, path := range []string{"", "."} {
+		program := parser.New(lexer.New(`remove("` + path + `")`)).ParseProgram()
+		testErrorObject(t, i, Eval(program, empty), "remove failed: "+path+": path is the allowed directory itself")
+	}
+	if _, err := os.Stat(empty.Runtime().Root); err != nil {
+		t.Errorf("root removed: %v", err)
+	}
+
+	disabled := testEval(`read_file("a.txt")`)
+	testErrorObject(t, 0, disabled, "read_file failed: a.txt: file access is disabled")
+}
+
+func TestInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		stdin    string
+		expected string
+		stdout   string
+	}{
+		{`input()`, "Ann\nBob\n", `"Ann"`, ""},
+		{`input("name? ")`, "Ann\n", `"Ann"`, "name? "},
+		{`input("name? ")`, "", "<void>", "name? "},
+		{`[read_line(), read_line(), read_line(), read_line()]`, "a\r\n\nb", `["a", "", "b", <void>]`, ""},
+		{`read_line(); read_all()`, "a\nb\nc", "\"b\nc\"", ""},
+		{`read_all()`, "", `""`, ""},
+		{`[l.upper() for l in lines()]`, "a\nb\n", `["A", "B"]`, ""},
+		{`read_line(); array(lines())`, "a\nb\nc", `["b", "c"]`, ""},
+		{`print(read_line(), "!")`, "a\n", "<void>", "a !\n"},
+		{`input(1)`, "", "[1:6] invalid argument: input(INTEGER)", ""},
+		{`input("a", "b")`, "", "[1:6] too many arguments for input: expected 1, found 2", ""},
+		{`read_line(1)`, "", "[1:10] wrong number of arguments for read_line: expected 0, found 1", ""},
+	}
+
+	for i, tt := range tests {
+		var stdout strings.Builder
+		env := object.NewEnvironment()
+		env.Runtime().SetStdin(strings.NewReader(tt.stdin))
+		env.Runtime().Stdout = &stdout
+
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		if actual := Eval(program, env).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+		if stdout.String() != tt.stdout {
+			t.Errorf("[%d] wrong output; expected %q, got %q", i, tt.stdout, stdout.String())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sprintf("plain")`, `"plain"`},
+		{`sprintf("%s and %v", "str", 1)`, `"str and 1"`},
+		{`sprintf("%q %q %q", "str", [1, "a"], {"k": "v"})`, `""str" [1, "a"] {"k": "v"}"`},
+		{`sprintf("%v", ["a"])`, `"["a"]"`},
+		{`sprintf("%.2f %e %g %v", PI, 1.5, 2, 0.5)`, `"3.14 1.500000e+00 2 0.5"`},
+		{`sprintf("%f", "a")`, `[1:8] invalid argument for sprintf: %f expects FLOAT, found STRING`},
+		{`sprintf("[%5s|%-5s|%.2s|%5.1s]", "žlu", "ab", "abc", "xyz")`, `"[  žlu|ab   |ab|    x]"`},
+		{`sprintf("[%5d|%-5d|%05d|%+d|%.3d]", 42, 42, -42, 3, 7)`, `"[   42|42   |-0042|+3|007]"`},
+		{`sprintf("%x %X %#x %o %b %c", 255, 255, 255, 8, 5, 382)`, `"ff FF 0xff 10 101 ž"`},
+		{`sprintf("100%%")`, `"100%"`},
+		{`sprintf("%6q", "a")`, `"   "a""`},
+		{`Box :: type("Box", [["__str", fn() { "box" }]]); sprintf("<%s>", Box(1))`, `"<box>"`},
+		{`Box :: type("Box", [["__str", fn() { "box" }]]); sprintf("%v %q", [Box(1)], [Box(2), "a"])`, `"[box] [box, "a"]"`},
+		{`sprintf("%d", "a")`, "[1:8] invalid argument for sprintf: %d expects INTEGER, found STRING"},
+		{`sprintf("%05x", true)`, "[1:8] invalid argument for sprintf: %05x expects INTEGER, found BOOLEAN"},
+		{`sprintf("%d %s", 1)`, "[1:8] invalid argument for sprintf: missing argument for %s"},
+		{`sprintf("%d", 1, 2)`, "[1:8] invalid argument for sprintf: too many arguments for format string, expected 1, found 2"},
+		{`sprintf("%-3z", 1)`, "[1:8] invalid argument for sprintf: unknown verb %-3z"},
+		{`sprintf("50%")`, "[1:8] invalid argument for sprintf: unterminated verb %"},
+		{`sprintf()`, "[1:8] not enough arguments for sprintf: expected 1, found 0"},
+		{`sprintf(1)`, "[1:8] invalid argument: sprintf(INTEGER, ...)"},
+		{`printf("%d", "a")`, "[1:7] invalid argument for printf: %d expects INTEGER, found STRING"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	var stdout strings.Builder
+	env := object.NewEnvironment()
+	env.Runtime().Stdout = &stdout
+	program := parser.New(lexer.New(`printf("%s=%03d;", "a", 7); print("b", ["c"], 1)`)).ParseProgram()
+	testVoidObject(t, 0, Eval(program, env))
+	if expected := "a=007;b [\"c\"] 1\n"; stdout.String() != expected {
+		t.Errorf("wrong output; expected %q, got %q", expected, stdout.String())
+	}
+}
+
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`regex("\d+")`, `regex("\d+")`},
+		{`re :: regex("b+"); [re.match("abbc"), re.match("ac"), match(regex("^a$"), "ab")]`, "[true, false, false]"},
+		{`regex("\d+").find_all("a1 b22 c333")`, `["1", "22", "333"]`},
+		{`regex("\d+").find_all("abc")`, "[]"},
+		{`regex("(\w+)@(\w+)").captures("mail ann@host and bob@home")`, `["ann@host", "ann", "host"]`},
+		{`regex("(a)|(b)").captures("b")`, `["b", "", "b"]`},
+		{`regex("x").captures("abc")`, "[]"},
+		{`regex("žl(.)").captures("žluť")`, `["žlu", "u"]`},
+		{`replace("a=1, b=2", regex("(\w)=(\d)"), "$2=$1")`, `"1=a, 2=b"`},
+		{`replace("ann bob", regex("(?P<first>\w)(\w*)"), "${first}.")`, `"a. b."`},
+		{`"a.b.c".replace(".", "-")`, `"a-b-c"`},
+		{`"a.b.c".replace(regex("."), "-")`, `"-----"`},
+		{`"a1b22c".split(regex("\d+"))`, `["a", "b", "c"]`},
+		{`"a, b ,c".split(regex("\s*,\s*"))`, `["a", "b", "c"]`},
+		{`"a,b".split(",")`, `["a", "b"]`},
+		{`regex("(a")`, "[1:7] invalid argument for regex: error parsing regexp: missing closing ): `(a`"},
+		{`regex("a**")`, "[1:7] invalid argument for regex: error parsing regexp: invalid nested repetition operator: `**`"},
+		{`p :: "(a"; regex(p)`, "[1:17] invalid argument for regex: error parsing regexp: missing closing ): `(a`"},
+		{`regex(1)`, "[1:6] invalid argument: regex(INTEGER)"},
+		{`match("a", regex("a"))`, `[1:6] invalid argument: match(STRING, REGEX)`},
+		{`regex("a").find_all()`, "[1:20] wrong number of arguments for find_all: expected 2, found 1"},
+		{`replace("a", regex("a"), 1)`, "[1:8] invalid argument: replace(STRING, REGEX, INTEGER)"},
+		{`split(regex("a"), "a")`, "[1:6] invalid argument: split(REGEX, STRING)"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[rand_int(3, 3), rand_int(-1, -1)]`, "[3, -1]"},
+		{`[rand_int(-2, 2) for i in 0..100].all(fn(n) { n in [-2, -1, 0, 1, 2] })`, "true"},
+		{`[random() for i in 0..10].all(fn(n) { n >= 0 })`, "true"},
+		{`[choice("a"), choice([1]), choice(5..6) >= 5]`, `["a", 1, true]`},
+		{`xs :: [1, 2, 3, 4]; [shuffle(xs).sort(), xs]`, "[[1, 2, 3, 4], [1, 2, 3, 4]]"},
+		{`shuffle([])`, "[]"},
+		{`seed(7)`, "<void>"},
+		{`rand_int(2, 1)`, "[1:9] invalid argument for rand_int: 2 is greater than 1"},
+		{`rand_int(1)`, "[1:9] wrong number of arguments for rand_int: expected 2, found 1"},
+		{`choice([])`, "[1:7] invalid argument for choice: empty sequence"},
+		{`choice(1)`, "[1:7] invalid argument for choice: cannot iterate over INTEGER"},
+		{`shuffle()`, "[1:8] wrong number of arguments for shuffle: expected 1, found 0"},
+		{`seed("a")`, "[1:5] invalid argument: seed(STRING)"},
+		{`random(1)`, "[1:7] wrong number of arguments for random: expected 0, found 1"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	// the same seed gives the same values in separate interpreters, however
+	// the other one is used in between
+	draw := `[random(), rand_int(1, 100), choice("abcdef"), shuffle(0..10)]`
+	first, second := object.NewEnvironment(), object.NewEnvironment()
+	Eval(parser.New(lexer.New("seed(42)")).ParseProgram(), first)
+	Eval(parser.New(lexer.New("seed(42)")).ParseProgram(), second)
+	a := Eval(parser.New(lexer.New(draw)).ParseProgram(), first).Inspect()
+	Eval(parser.New(lexer.New("seed(1); "+draw)).ParseProgram(), object.NewEnvironment())
+	b := Eval(parser.New(lexer.New(draw)).ParseProgram(), second).Inspect()
+	if a != b {
+		t.Errorf("seeded interpreters differ: %s and %s", a, b)
+	}
+	if c := Eval(parser.New(lexer.New(draw)).ParseProgram(), first).Inspect(); c == a {
+		t.Errorf("random values repeated without reseeding: %s", c)
+	}
+
+	// unseeded interpreters draw the same values at the same time
+	at := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	draws := []string{}
+	for i := 0; i < 2; i++ {
+		env := object.NewEnvironment()
+		env.Runtime().Clock = object.NewManualClock(at)
+		draws = append(draws, Eval(parser.New(lexer.New(draw)).ParseProgram(), env).Inspect())
+	}
+	if draws[0] != draws[1] {
+		t.Errorf("interpreters with the same clock differ: %s and %s", draws[0], draws[1])
+	}
+
+	extremes := testEval(`rand_int(-9223372036854775807 - 1, 9223372036854775807)`)
+	if _, ok := extremes.(*object.Integer); !ok {
+		t.Errorf("wrong result for the full range; got %s", extremes.Inspect())
+	}
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`now()`, "1709208000000"},
+		{`unix()`, "1709208000"},
+		{`c :: clock(); sleep(1500); [clock() - c, now()]`, "[1500, 1709208001500]"},
+		{`sleep(2 * MINUTE); format_time(now())`, `"2024-02-29T12:02:00Z"`},
+		{`format_time(now() + DAY, "2006-01-02 15:04")`, `"2024-03-01 12:00"`},
+		{`format_time(-1)`, `"1969-12-31T23:59:59Z"`},
+		{`parse_time("2024-02-29T12:00:00Z") == now()`, "true"},
+		{`parse_time("2024-02-29T13:00:00+01:00") == now()`, "true"},
+		{`parse_time("1.3.2024", "2.1.2006") - now()`, "43200000"},
+		{`[duration("1h30m"), duration("-1.5s"), duration("1us")]`, "[5400000, -1500, 0]"},
+		{`format_duration(HOUR + 30 * SECOND + 5)`, `"1h0m30.005s"`},
+		{`format_duration(0)`, `"0s"`},
+		{`sleep(-1)`, "[1:6] invalid argument for sleep: negative duration -1"},
+		{`sleep("1")`, "[1:6] invalid argument: sleep(STRING)"},
+		{`format_duration(9223372036854775807)`, "[1:16] integer overflow: format_duration(9223372036854775807)"},
+		{`format_time("now")`, `[1:12] invalid argument: format_time(STRING)`},
+		{`format_time(1, 2)`, `[1:12] invalid argument: format_time(INTEGER, INTEGER)`},
+		{`format_time()`, `[1:12] wrong number of arguments for format_time: expected 1 or 2, found 0`},
+		{`parse_time("29.2.2024")`, `[1:11] invalid argument for parse_time: parsing time "29.2.2024" as "2006-01-02T15:04:05Z07:00": cannot parse "29.2.2024" as "2006"`},
+		{`duration("soon")`, `[1:9] invalid argument for duration: time: invalid duration "soon"`},
+		{`now(1)`, "[1:4] wrong number of arguments for now: expected 0, found 1"},
+	}
+
+	for i, tt := range tests {
+		env := object.NewEnvironment()
+		env.Runtime().Clock = object.NewManualClock(time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC))
+
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		if actual := Eval(program, env).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	start := time.Now()
+	testBooleanObject(t, 0, testEval(`c :: clock(); sleep(20); clock() - c >= 20`), true)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("sleep did not wait; took %v", elapsed)
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int
+	}{
+		{`[0, 1, 2]`, []int{0, 1, 2}},
+		{`[]`, []int{}},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		array, ok := eval.(*object.ArrayLiteral)
+		if !ok {
+			t.Errorf("[%d] object is not Array, got %T", i, eval)
+			continue
+		}
+
+		for j, expected := range tt.expected {
+			item, ok := array.Items[j].(*object.Integer)
+			if !ok {
+				t.Errorf("[%d] array item %d is not Integer, got %s", i, j, array.Items[j].Type())
+				continue
+			}
+
+			if item.Value != int64(expected) {
+				t.Errorf("[%d] array item %d has wrong value; expected %d, got %d", i, j, expected, item.Value)
+			}
+		}
+	}
+}
+
+func TestArrayAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`[0, 1, 2, 3][2]`, 2},
+		{`[42, "abc", true][1]`, "abc"},
+		{`arr :: [true, false]; arr[0]`, true},
+		{`foo :: [fn(bar) { len(bar) }]; foo[0]("12345")`, 5},
+		{`[3, 2, 1][-2]`, 2},
+		{`[3, 2, 1][-3]`, 3},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			testStringObject(t, i, eval, expected)
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		}
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"a :: 1", 1},
+		{"a := 2", 2},
+		{"a :: b := 3; a + b", 6},
+		{"a := 4; a = 5", 5},
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"xs := [1, 2]; xs[0] = 5; xs[0]", 5},
+		{"xs := [1, 2]; xs[-1] = 7; xs[1]", 7},
+		{"xs :: [1, 2]; xs[0] = 5", "cannot mutate frozen ARRAY"},
+		{"xs :: [[1], [2]]; xs[1][0] = 5", "cannot mutate frozen ARRAY"},
+		{"xs := freeze([1, 2]); xs[0] = 5", "cannot mutate frozen ARRAY"},
+		{"inner := [1]; xs :: [inner]; inner[0] = 2; xs[0][0] + inner[0]", 3},
+		{"inner := [1]; xs :: [inner]; xs[0][0] = 2", "cannot mutate frozen ARRAY"},
+		{"xs := [1]; ys := freeze(xs); xs[0] = 2; ys[0]", 1},
+		{"xs := [1]; xs[0] = xs; ys :: xs; ys[0][0][0] = 1", "cannot mutate frozen ARRAY"},
+		{"xs := [1]; xs[0] = xs; ys :: xs; xs[0] = 2; len(ys[0][0][0])", 1},
+		{`m := {"a": [1]}; n :: m; m.a[0] = 2; n.a[0]`, 1},
+		{`m := {"a": [1]}; n :: m; n.a[0] = 2`, "cannot mutate frozen ARRAY"},
+		{"class C { }; c := C(); c.x = [1]; d :: c; c.x[0] = 2; d.x[0]", 1},
+		{"class C { }; c := C(); c.x = 1; d :: c; d.x = 2", "cannot mutate frozen C"},
+		{"enum E { V(xs) }; xs := [1]; v :: V(xs); xs[0] = 2; v.xs[0]", 1},
+		{`T :: type("T", []); t :: T([1]); t.value[0] = 2`, "cannot mutate frozen ARRAY"},
+		{`T :: type("T", []); xs := [1]; t :: T(xs); xs[0] = 2; t.value[0]`, 1},
+		{"xs :: [1, 2]; ys := append(xs, 3); ys[0] = 9; xs[0]", 1},
+		{"xs := [1, 2, 3]; ys := tail(xs); ys[0] = 9; xs[1]", 2},
+		{"xs := [1, 2]; a := append(xs, 3); b := append(xs, 4); a[2]", 3},
+		{"xs := [1]; xs[1] = 2", "invalid argument: index 1 out of bounds"},
+		{"s := \"abc\"; s[0] = \"x\"", "invalid argument: STRING[INTEGER] = STRING"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	count := `
+count :: fn(n) {
+	aux :: fn(i) {
+		if i < n {
+			yield i
+			aux(i + 1)
+		}
+	}
+	aux(0)
+}
+`
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{count + "g :: count(3); next(g); next(g)", 1},
+		{count + "g :: count(1); next(g); next(g, 42)", 42},
+		{count + "g :: count(1); next(g); next(g)", "invalid argument for next: iterator exhausted"},
+		{count + "len(array(count(100)))", 100},
+		{count + "first(count(5))", 0},
+		{"g :: fn() { yield 1; return 5; yield 2 }(); next(g); next(g, 0)", 0},
+		{"g :: fn() { yield 1; yield -true }(); next(g); next(g)", "unknown operator: -BOOLEAN"},
+		{"pairs :: fn(xs) { yield [first(xs), 1] }; next(pairs([7]))[0]", 7},
+		{"mk :: fn(n) { fn() { yield n } }; next(mk(5)())", 5},
+		{"mk :: fn(n) { fn() { yield n; yield n + 1 } }; g :: mk(5); len(array(g())) + len(array(g()))", 4},
+		{"inner :: fn() { yield 1; yield 2 }; outer :: fn() { g :: inner(); yield next(g) * 10; yield next(g) * 10 }; array(outer())[1]", 20},
+		{"len(array(fn() { yield 1; yield 2 }()))", 2},
+		{`array("ěšč")[1]`, "š"},
+		{"next([1])", "invalid argument: next(ARRAY)"},
+		{"array(1)", "invalid argument: array(INTEGER)"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			if _, ok := eval.(*object.Error); ok {
+				testErrorObject(t, i, eval, expected)
+			} else {
+				testStringObject(t, i, eval, expected)
+			}
+		}
+	}
+}
+
+func TestGeneratorAbandoned(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		testEval("naturals :: fn() { aux :: fn(i) { yield i; aux(i + 1) }; aux(0) }; first(naturals())")
+	}
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("abandoned generators leaked goroutines; before %d, after %d", before, after)
+	}
+}
+
+func TestGeneratorClosed(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"g :: fn() { yield 1; yield 2; yield 3 }(); [find(g, fn(x) { x == 2 }), next(g, 0)]", "[2, 0]"},
+		{"g :: fn() { yield 1; yield 2 }(); [any(g, fn(x) { x == 1 }), next(g, 0)]", "[true, 0]"},
+		{"g :: fn() { yield 1; yield 2 }(); zip(g, [1]); next(g, 0)", "0"},
+		{"g :: fn() { yield 1; yield 2 }(); [zip([1], g), next(g, 0)]", "[[[1, 1]], 0]"},
+		{"g :: fn() { yield 1; yield 2 }(); [first(g), next(g)]", "[1, 2]"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong result; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	failing := []string{
+		"each(g, fn(x) { -true })",
+		"[x + true for x in g]",
+		"[x for x in g if x]",
+	}
+
+	for i, input := range failing {
+		env := object.NewEnvironment()
+		program := parser.New(lexer.New("g :: fn() { yield 1; yield 2 }(); " + input)).ParseProgram()
+		if res := Eval(program, env); res.Type() != object.ERROR_OBJ {
+			t.Errorf("[%d] expected an error, got %s", i, res.Inspect())
+		}
+		g, _ := env.Get("g")
+		if _, ok := g.(*object.Generator).Next(); ok {
+			t.Errorf("[%d] generator not closed after an error", i)
+		}
+	}
+
+	// generators reachable from their own environment are never collected
+	// and must be closed by the runtime
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		env := object.NewEnvironment()
+		program := parser.New(lexer.New("gen := fn() { yield 1; yield 2 }; g := gen(); next(g)")).ParseProgram()
+		testIntegerObject(t, i, Eval(program, env), 1)
+		env.Runtime().Close()
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("stored generators leaked goroutines; before %d, after %d", before, after)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"ch :: channel(); spawn fn() { send(ch, 42) }(); recv(ch)", 42},
+		{"ch :: channel(); sq :: fn(n) { send(ch, n * n) }; spawn sq(3); spawn sq(4); recv(ch) + recv(ch)", 25},
+		{"t :: spawn fn(a, b) { a * b }(6, 7); wait(t)", 42},
+		{"t :: spawn fn() { -true }(); wait(t)", "unknown operator: -BOOLEAN"},
+		{`
+ch :: channel(2)
+producer :: fn(n) {
+	if n > 0 {
+		send(ch, n)
+		producer(n - 1)
+	} else {
+		close(ch)
+	}
+}
+spawn producer(10)
+len(array(ch))
+`, 10},
+		{"ch :: channel(1); close(ch); recv(ch, 5)", 5},
+		{"ch :: channel(1); close(ch); recv(ch)", "invalid argument for recv: channel is closed"},
+		{"ch :: channel(1); close(ch); send(ch, 1)", "channel is closed"},
+		{"ch :: channel(1); close(ch); close(ch)", "channel is closed"},
+		{"ch :: channel(); recv(ch)", "deadlock: all tasks are blocked"},
+		{"ch :: channel(); send(ch, 1)", "deadlock: all tasks are blocked"},
+		{"ch :: channel(); t :: spawn fn() { recv(ch) }(); wait(t)", "deadlock: all tasks are blocked"},
+		{"a :: channel(); b :: channel(); select { recv(a) => 1, recv(b) => 2 }", "deadlock: all tasks are blocked"},
+		{"a :: channel(); select { recv(a) => 1, default => 2 }", 2},
+		{"a :: channel(1); b :: channel(1); send(b, 5); select { recv(a) => 1, v := recv(b) => v * 2 }", 10},
+		{"a :: channel(1); select { send(a, 3) => recv(a) }", 3},
+		{"a :: channel(); spawn fn() { send(a, 4) }(); select { x := recv(a) => x }", 4},
+		{"a :: channel(); spawn fn() { recv(a) }(); select { send(a, 1) => 9 }", 9},
+		{"a :: channel(); spawn fn() { select { send(a, 8) => 0 } }(); recv(a)", 8},
+		{"select { recv(1) => 1 }", "invalid argument: recv(INTEGER) in select"},
+		{"wait(1)", "invalid argument: wait(INTEGER)"},
+		{"channel(-1)", "invalid argument: channel(-1)"},
+		{"base :: 10; ch :: channel(); add :: fn(n) { x := n + base; send(ch, x) }; spawn add(1); spawn add(2); spawn add(3); recv(ch) + recv(ch) + recv(ch)", 36},
+		{`m := {}; done :: channel(); w :: fn(k) { each(0..1000, fn(i) { m[k] = i; x := "a" in m; len(m) }); send(done, 1) }; spawn w("a"); spawn w("b"); spawn w("c"); recv(done); recv(done); recv(done); len(m) + m.a`, 1002},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+}
+
+// TestSharedContainers mutates containers and advances generators from
+// several tasks at once, which the race detector checks when the tests run
+// with -race.
+func TestSharedContainers(t *testing.T) {
+	workers := `
+done :: channel()
+run :: fn(work) {
+	spawn fn() { each(0..500, work); send(done, 1) }()
+	spawn fn() { each(0..500, work); send(done, 1) }()
+	spawn fn() { each(0..500, work); send(done, 1) }()
+	recv(done); recv(done); recv(done)
+}
+`
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{workers + `xs := [0, 0, 0]; run(fn(n) { xs[n / 200] = n; ys :: xs; [x for x in xs]; sprintf("%v", xs) }); xs[2]`, 499},
+		{workers + `class C { }; c := C(); run(fn(n) { c.a = n; c.b = c.a; d :: c; c == d; sprintf("%v", c) }); c.a - c.b + 1`, 1},
+		{workers + `m := {}; run(fn(n) { m.a = n; m["b"] = m.a; f :: m; json_stringify(f); keys(m) }); len(m)`, 2},
+		{workers + `g :: fn() { aux :: fn(i) { yield i; aux(i + 1) }; aux(0) }(); run(fn(n) { next(g) }); next(g)`, 1500},
+	}
+
+	for i, tt := range tests {
+		testIntegerObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "5"},
+		{"quote(5 + 8)", "(5 + 8)"},
+		{"quote(foo + bar)", "(foo + bar)"},
+		{"quote(unquote(4 + 4))", "8"},
+		{"quote(8 + unquote(4 + 4))", "(8 + 8)"},
+		{"quote(unquote(-2))", "(-2)"},
+		{"quote(unquote(1.0 / 4.0))", "0.25"},
+		{"quote(unquote(-2.0))", "(-2.0)"},
+		{"quote(unquote(true == false))", "false"},
+		{`quote(unquote("a" + "b"))`, `"ab"`},
+		{"quote(unquote([1, 2]))", "[1, 2]"},
+		{"q :: quote(4 + 4); quote(unquote(4 + 4) + unquote(q))", "(8 + (4 + 4))"},
+		{"quote(fn(x) { y := x })", "fn(x#N) { y#N := x#N }"},
+	}
+
+	for i, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		q, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("[%d] expected *object.Quote, got %T (%+v)", i, evaluated, evaluated)
+			continue
+		}
+
+		if actual := gensymPattern.ReplaceAllString(q.Node.String(), "#N"); actual != tt.expected {
+			t.Errorf("[%d] wrong quoted node; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestUnquoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(unquote(1 + true))", "type mismatch: INTEGER + BOOLEAN"},
+		{"quote(1 + unquote(missing))", "identifier not found: missing"},
+		{"quote(unquote(fn() { 1 }))", "cannot unquote FUNCTION"},
+		{"quote(unquote(1.0 / 0.0))", "cannot unquote FLOAT"},
+		{"quote(unquote([1, fn() { 1 }]))", "cannot unquote ARRAY"},
+	}
+
+	for i, tt := range tests {
+		testErrorObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`
+unless :: macro(cond, then, otherwise) {
+	quote(if !(unquote(cond)) { unquote(then) } else { unquote(otherwise) })
+}
+unless(10 > 5, 1, 2)
+`, 2},
+		{`
+twice :: macro(e) { quote(fn() { tmp := unquote(e); tmp * 2 }()) }
+tmp := 21
+twice(tmp)
+`, 42},
+		{`
+twice :: macro(e) { quote(fn() { tmp := unquote(e); tmp * 2 }()) }
+twice(1) + twice(2)
+`, 6},
+		{`
+square :: macro(e) { quote(unquote(e) * unquote(e)) }
+calls := [0]
+count :: fn(n) { calls[0] = calls[0] + 1; n }
+square(count(3)) + calls[0] * 100
+`, 209},
+		{`
+sq :: macro(e) { quote(unquote(e) * unquote(e)) }
+twice :: macro(e) { quote(sq(unquote(e)) + sq(unquote(e))) }
+twice(3)
+`, 18},
+		{"m :: macro(x) { quote(fn() { class A { fn get() { unquote(x) } }; A().get() }()) }; m(5)", 5},
+		{"m :: macro(x) { quote(fn() { get := 2; class A { fn get() { get * unquote(x) } }; A().get() }()) }; m(5)", 10},
+		{"m :: macro(e) { quote(m(unquote(e))) }; m(1)", "macro m expanded more than 100 times"},
+		{"m :: macro(a) { 1 }; m(2)", "macro m must return a quote, got INTEGER"},
+		{"m :: macro(a) { quote(a) }; m()", "wrong number of arguments for macro m: expected 1, found 0"},
+		{"f :: fn() { macro(x) { x } }; f()", "macro can only be defined at the top level"},
+	}
+
+	for i, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, errors := ExpandMacros(program, env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			if len(errors) > 0 {
+				t.Errorf("[%d] unexpected expansion errors: %v", i, errors)
+				continue
+			}
+			testIntegerObject(t, i, Eval(expanded, object.NewEnvironment()), int64(expected))
+		case string:
+			if len(errors) == 0 {
+				testErrorObject(t, i, Eval(expanded, object.NewEnvironment()), expected)
+			} else if !strings.HasSuffix(errors[0], expected) {
+				t.Errorf("[%d] wrong expansion error; expected %q, got %q", i, expected, errors[0])
+			}
+		}
+	}
+}
+
+func TestEnum(t *testing.T) {
+	shape := "enum Shape { Circle(r), Rect(w, h), Empty }; "
+	area := "area :: fn(s) { case s { Circle(r) => 3 * r * r, Rect(w, h) => { w * h }; Empty => 0 } }; "
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{shape + area + "area(Circle(2))", 12},
+		{shape + area + "area(Rect(2, 3))", 6},
+		{shape + area + "area(Empty)", 0},
+		{shape + "Circle(1) == Circle(1)", true},
+		{shape + "Rect(1, 2) == Rect(2, 1)", false},
+		{shape + "Rect(1, 2) != Circle(1)", true},
+		{shape + "Empty == Empty", true},
+		{shape + "Circle(Rect(1, 2)) == Circle(Rect(1, 2))", true},
+		{shape + "case Rect(1, 2) { Circle(_) => 1, _ => 2 }", 2},
+		{shape + "case Rect(1, 2) { Rect(_, h) => h }", 2},
+		{shape + "case Rect(1, 2) { Circle(r) => r }", "unhandled variant in case: Shape.Rect"},
+		{shape + "case Empty { Square(a) => a }", "Shape has no variant Square"},
+		{shape + "case Circle(1) { Circle(a, b) => a }", "wrong number of bindings for Circle: expected 1, found 2"},
+		{shape + "Rect(1)", "wrong number of arguments for Rect: expected 2, found 1"},
+		{shape + "Circle = 1", "assigning to const: Circle"},
+		{"Circle := 1; enum Shape { Circle(r) }", "identifier already declared: Circle"},
+		{"case 1 { _ => 1 }", "case expects an enum variant, got INTEGER"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+
+	inspected := []struct {
+		input    string
+		expected string
+	}{
+		{shape + "Rect(1, [2])", "Rect(1, [2])"},
+		{shape + "Empty", "Empty"},
+		{shape + "Circle", "<constructor Shape.Circle(r)>"},
+		{shape + "Shape", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+	}
+
+	for i, tt := range inspected {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong inspect; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"len(0..10)", 10},
+		{"len(0..=10)", 11},
+		{"len(10..0)", 0},
+		{"len(0..10 by 3)", 4},
+		{"len(0..=9 by 3)", 4},
+		{"len(10..0 by -2)", 5},
+		{"len(10..=0 by -2)", 6},
+		{"len(0..1000000000000000)", 1000000000000000},
+		{"len(0..9223372036854775807)", 9223372036854775807},
+		{"len(0..=9223372036854775807)", "integer overflow: len(0..=9223372036854775807)"},
+		{"len(-9223372036854775807..9223372036854775807)", "integer overflow: len(-9223372036854775807..9223372036854775807)"},
+		{"len(9223372036854775807..=-9223372036854775807 by -9223372036854775807)", 3},
+		{"len(array(9223372036854775806..=9223372036854775807))", 2},
+		{"(-9223372036854775807..9223372036854775807)[-1]", 9223372036854775806},
+		{"(-9223372036854775807..9223372036854775807)[9223372036854775807]", 0},
+		{"9223372036854775807 in -1..=9223372036854775807", true},
+		{"9223372036854775807 in -9223372036854775807..9223372036854775807", false},
+		{"len(tail(9223372036854775807..=9223372036854775807))", 0},
+		{"(0..10 by 2)[3]", 6},
+		{"(0..10)[-1]", 9},
+		{"(0..10)[10]", "invalid argument: index 10 out of bounds"},
+		{"(10..=0 by -5)[2]", 0},
+		{"5 in 0..10", true},
+		{"10 in 0..10", false},
+		{"10 in 0..=10", true},
+		{"4 in 0..10 by 3", false},
+		{"6 in 0..10 by 3", true},
+		{`"1" in 0..10`, false},
+		{"3 in [1, 2, 3]", true},
+		{`"bc" in "abc"`, true},
+		{"1 in 5", "unknown operator: INTEGER in INTEGER"},
+		{"first(tail(3..6))", 4},
+		{"len(tail(0..0))", 0},
+		{"len(array(0..100 by 7))", 15},
+		{"0..1 == 0..1", true},
+		{"0..1 == 0..=1", false},
+		{"0..10 by 0", "invalid range: step cannot be 0"},
+		{`0.."a"`, "invalid range: INTEGER..STRING"},
+	}
+
+	for i, tt := range tests {
+		eval := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, i, eval, int64(expected))
+		case bool:
+			testBooleanObject(t, i, eval, expected)
+		case string:
+			testErrorObject(t, i, eval, expected)
+		}
+	}
+
+	arrays := []struct {
+		input    string
+		expected string
+	}{
+		{"array(0..5)", "[0, 1, 2, 3, 4]"},
+		{"array(1..=10 by 4)", "[1, 5, 9]"},
+		{"array(5..0 by -2)", "[5, 3, 1]"},
+		{"array(0..0)", "[]"},
+		{"0..=10 by 2", "0..=10 by 2"},
+	}
+
+	for i, tt := range arrays {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong value; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestComprehension(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs := [1, 2, 3]; [x * 2 for x in xs if x > 1]", "[4, 6]"},
+		{"[x for x in []]", "[]"},
+		{`[c + c for c in "ab"]`, `["aa", "bb"]`},
+		{"[x * x for x in 1..=4]", "[1, 4, 9, 16]"},
+		{"[[y for y in 0..x] for x in 1..3]", "[[0], [0, 1]]"},
+		{"fs := [fn() { x } for x in 0..3]; [f() for f in fs]", "[0, 1, 2]"},
+		{"g :: fn() { yield 1; yield 2 }; [x + 1 for x in g()]", "[2, 3]"},
+	}
+
+	for i, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("[%d] wrong value; expected %q, got %q", i, tt.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"[x for x in 5]", "invalid argument: cannot iterate over INTEGER"},
+		{"[x for x in [1] if 1]", "non-boolean condition in comprehension: INTEGER"},
+		{"[x for x in [1]]; x", "identifier not found: x"},
+		{"[-x for x in [true]]", "unknown operator: -BOOLEAN"},
+	}
+
+	for i, tt := range errors {
+		testErrorObject(t, i, testEval(tt.input), tt.expected)
+	}
+}
+
+/* HELPERS */
+
+var gensymPattern = regexp.MustCompile(`#[0-9]+`)
+
+func testEval(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return &object.Error{Message: "program could not be parsed"}
+	}
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, i int, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("[%d] object is not Integer, got %T", i, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("[%d] object has wrong value, expected %d, got %d", i, expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
+func testFloatObject(t *testing.T, i int, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("[%d] object is not Float, got %T", i, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("[%d] object has wrong value, expected %g, got %g", i, expected, result.Value)
 		return false
 	}
 
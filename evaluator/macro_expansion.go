@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"baboon/ast"
+	"baboon/object"
+	"baboon/token"
+)
+
+// DefineMacros removes top-level macro definitions from program and binds
+// them in env.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+
+	for _, stmt := range program.Statements {
+		assign, ok := macroDefinition(stmt)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		lit := assign.Value.(*ast.MacroLiteral)
+		macro := &object.Macro{Parameters: lit.Parameters, Body: lit.Body, Env: env}
+		env.Set(assign.Name.Value, macro)
+	}
+
+	program.Statements = statements
+}
+
+func macroDefinition(stmt ast.Statement) (*ast.AssignExpression, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return nil, false
+	}
+
+	assign, ok := es.Expression.(*ast.AssignExpression)
+	if !ok || assign.Token.Type == token.ASSIGN {
+		return nil, false
+	}
+
+	_, ok = assign.Value.(*ast.MacroLiteral)
+	return assign, ok
+}
+
+// maxExpansions bounds the rounds of expansion, which would not end for a
+// macro expanding to a call of itself.
+const maxExpansions = 100
+
+// ExpandMacros replaces every call of a macro defined in env with the code
+// it returns, until no macro calls are left. Errors are reported in the same
+// format as parser errors.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, []string) {
+	for round := 0; ; round++ {
+		expanded, last, errors := expandMacros(program, env)
+		if len(errors) > 0 || last == nil {
+			return expanded, errors
+		}
+		if round == maxExpansions {
+			msg := fmt.Sprintf("[%d:%d] macro %s expanded more than %d times", last.Token.Line, last.Token.Column, last.Function.String(), maxExpansions)
+			return expanded, []string{msg}
+		}
+		program = expanded
+	}
+}
+
+// expandMacros expands the macro calls in program once, returning the last
+// call expanded, if any. Calls in the code returned by macros are left as
+// they are.
+func expandMacros(program ast.Node, env *object.Environment) (ast.Node, *ast.CallExpression, []string) {
+	errors := []string{}
+	var last *ast.CallExpression
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		macro, ok := macroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			msg := fmt.Sprintf("[%d:%d] wrong number of arguments for macro %s: expected %d, found %d", call.Token.Line, call.Token.Column, call.Function.String(), len(macro.Parameters), len(call.Arguments))
+			errors = append(errors, msg)
+			return node
+		}
+
+		evalEnv := object.NewEnclosedEnvironment(macro.Env)
+		for i, param := range macro.Parameters {
+			evalEnv.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+		}
+
+		evaluated := Eval(macro.Body, evalEnv)
+		if ret, ok := evaluated.(*object.Return); ok {
+			evaluated = ret.Value
+		}
+
+		switch res := evaluated.(type) {
+		case *object.Quote:
+			last = call
+			return res.Node
+		case *object.Error:
+			errors = append(errors, res.Inspect())
+		default:
+			msg := fmt.Sprintf("[%d:%d] macro %s must return a quote, got %s", call.Token.Line, call.Token.Column, call.Function.String(), evaluated.Type())
+			errors = append(errors, msg)
+		}
+		return node
+	})
+
+	return expanded, last, errors
+}
+
+func macroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
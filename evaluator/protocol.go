@@ -0,0 +1,110 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"baboon/object"
+	"baboon/token"
+)
+
+// callProtocol calls the method implementing the protocol called name on
+// obj. It reports false if obj does not implement the protocol.
+func callProtocol(obj object.Object, name string, args []object.Object, token *token.Token) (object.Object, bool) {
+	m, ok := obj.(object.Methoder)
+	if !ok {
+		return nil, false
+	}
+	method, ok := m.Method(name)
+	if !ok {
+		return nil, false
+	}
+	return applyFunction(method, args, method.Env, token), true
+}
+
+// protocolResult checks that a protocol method returned a value of the
+// expected type.
+func protocolResult(name string, res object.Object, expected object.ObjectType, token *token.Token) object.Object {
+	if res.Type() == object.ERROR_OBJ || res.Type() == expected {
+		return res
+	}
+	return newError(token, fmt.Sprintf("%s must return %s, found %s", name, expected, res.Type()))
+}
+
+// display returns the string printed for obj, using __str for obj and the
+// values it contains if implemented.
+func display(obj object.Object, token *token.Token) object.Object {
+	if d, ok := obj.(object.Displayer); ok {
+		return &object.String{Value: d.Display()}
+	}
+	return inspect(obj, token)
+}
+
+// inspect returns the debug form of obj, using __str for obj and the values
+// it contains if implemented. The first error raised by __str is returned.
+func inspect(obj object.Object, token *token.Token) object.Object {
+	var err object.Object
+	str := object.InspectWith(obj, func(obj object.Object) (string, bool) {
+		if err != nil {
+			return "", false
+		}
+		res, ok := callProtocol(obj, "__str", nil, token)
+		if !ok {
+			return "", false
+		}
+		res = protocolResult("__str", res, object.STRING_OBJ, token)
+		if s, ok := res.(*object.String); ok {
+			return s.Value, true
+		}
+		err = res
+		return "", false
+	})
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: str}
+}
+
+// equal compares a and b using the __eq method of a if implemented, then
+// that of b, and object.Equal otherwise.
+func equal(a object.Object, b object.Object, token *token.Token) object.Object {
+	if res, ok := callProtocol(a, "__eq", []object.Object{b}, token); ok {
+		return protocolResult("__eq", res, object.BOOLEAN_OBJ, token)
+	}
+	if res, ok := callProtocol(b, "__eq", []object.Object{a}, token); ok {
+		return protocolResult("__eq", res, object.BOOLEAN_OBJ, token)
+	}
+	return newBoolean(object.Equal(a, b))
+}
+
+// iterate returns an iterator over obj, using the result of __iter if
+// implemented. Errors raised by __iter are yielded by the iterator.
+func iterate(obj object.Object, token *token.Token) (object.Iterator, bool) {
+	res, ok := callProtocol(obj, "__iter", nil, token)
+	if !ok {
+		return object.Iterate(obj)
+	}
+	if res.Type() == object.ERROR_OBJ {
+		return &errorIterator{err: res}, true
+	}
+
+	it, ok := object.Iterate(res)
+	if !ok {
+		err := newError(token, fmt.Sprintf("__iter must return an iterable, found %s", res.Type()))
+		return &errorIterator{err: err}, true
+	}
+	return it, true
+}
+
+// errorIterator yields a single error.
+type errorIterator struct {
+	err object.Object
+}
+
+func (ei *errorIterator) Next() (object.Object, bool) {
+	if ei.err == nil {
+		return nil, false
+	}
+	err := ei.err
+	ei.err = nil
+	return err, true
+}
@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"baboon/ast"
+	"baboon/object"
+	"baboon/token"
+)
+
+// gensym numbers the identifiers renamed to keep quoted code hygienic.
+var gensym int64
+
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node, spliced, err := evalUnquoteCalls(node, env)
+	if err != nil {
+		return err
+	}
+	node = renameBindings(node)
+	node = ast.Modify(node, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if replacement, ok := spliced[ident.Value]; ok {
+				return replacement
+			}
+		}
+		return node
+	})
+
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls evaluates the arguments of all unquote calls in env and
+// replaces the calls with placeholder identifiers, returning the nodes to
+// splice in their place. Placeholders keep the spliced code out of reach of
+// renameBindings. The first error raised by an argument, or the first value
+// that cannot be spliced, is returned as an error.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, map[string]ast.Node, *object.Error) {
+	spliced := map[string]ast.Node{}
+	var err *object.Error
+
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if err != nil || !ok || !isUnquoteCall(call) || len(call.Arguments) != 1 {
+			return node
+		}
+
+		val := Eval(call.Arguments[0], env)
+		if e, ok := val.(*object.Error); ok {
+			err = e
+			return node
+		}
+		replacement, ok := convertObjectToASTNode(val, call.Token)
+		if !ok {
+			err = newError(&call.Token, fmt.Sprintf("cannot unquote %s", val.Type()))
+			return node
+		}
+
+		placeholder := fmt.Sprintf("%s%d", splicePrefix, len(spliced))
+		spliced[placeholder] = replacement
+		return &ast.Identifier{Token: call.Token, Value: placeholder}
+	})
+
+	return node, spliced, err
+}
+
+// splicePrefix cannot occur in source identifiers, so placeholders never
+// clash with user code.
+const splicePrefix = "unquote#"
+
+func isSplice(ident *ast.Identifier) bool {
+	return strings.HasPrefix(ident.Value, splicePrefix)
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
+
+// renameBindings gives every name declared by the quoted code a fresh name,
+// so that it can neither capture nor shadow identifiers at the expansion site.
+func renameBindings(node ast.Node) ast.Node {
+	renamed := map[string]string{}
+	bind := func(ident *ast.Identifier) {
+		if isSplice(ident) {
+			return
+		}
+		if _, ok := renamed[ident.Value]; !ok {
+			renamed[ident.Value] = fmt.Sprintf("%s#%d", ident.Value, atomic.AddInt64(&gensym, 1))
+		}
+	}
+
+	ast.Modify(node, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.AssignExpression:
+			if node.Token.Type != token.ASSIGN {
+				bind(node.Name)
+			}
+		case *ast.FunctionExpression:
+			for _, param := range node.Parameters {
+				bind(param)
+			}
+		case *ast.Comprehension:
+			bind(node.Variable)
+		case *ast.FunctionDeclaration:
+			bind(node.Name)
+		case *ast.CaseExpression:
+			for _, arm := range node.Arms {
+				for _, binding := range arm.Bindings {
+					bind(binding)
+				}
+			}
+		}
+		return node
+	})
+
+	if len(renamed) == 0 {
+		return node
+	}
+
+	return ast.Modify(node, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			if name, ok := renamed[ident.Value]; ok {
+				return &ast.Identifier{Token: ident.Token, Value: name}
+			}
+		}
+		return node
+	})
+}
+
+func convertObjectToASTNode(obj object.Object, tok token.Token) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: fmt.Sprint(obj.Value), Line: tok.Line, Column: tok.Column}
+		if obj.Value < 0 {
+			// negative literals do not exist, they are parsed as prefix expressions
+			t.Literal = fmt.Sprint(-obj.Value)
+			return &ast.PrefixExpression{
+				Token:    token.Token{Type: token.MINUS, Literal: "-", Line: tok.Line, Column: tok.Column},
+				Operator: "-",
+				Right:    &ast.IntegerLiteral{Token: t, Value: -obj.Value},
+			}, true
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Float:
+		// infinities and NaN have no literals
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil, false
+		}
+		abs := math.Abs(obj.Value)
+		literal := strconv.FormatFloat(abs, 'f', -1, 64)
+		if !strings.Contains(literal, ".") {
+			literal += ".0"
+		}
+		t := token.Token{Type: token.FLOAT, Literal: literal, Line: tok.Line, Column: tok.Column}
+		lit := &ast.FloatLiteral{Token: t, Value: abs}
+		if math.Signbit(obj.Value) {
+			return &ast.PrefixExpression{
+				Token:    token.Token{Type: token.MINUS, Literal: "-", Line: tok.Line, Column: tok.Column},
+				Operator: "-",
+				Right:    lit,
+			}, true
+		}
+		return lit, true
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Line: tok.Line, Column: tok.Column}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Literal: "false", Line: tok.Line, Column: tok.Column}
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true", Line: tok.Line, Column: tok.Column}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+
+	case *object.ArrayLiteral:
+		items := []ast.Expression{}
+		for _, item := range obj.Elements() {
+			node, ok := convertObjectToASTNode(item, tok)
+			exp, isExp := node.(ast.Expression)
+			if !ok || !isExp {
+				return nil, false
+			}
+			items = append(items, exp)
+		}
+		t := token.Token{Type: token.LBRACKET, Literal: "[", Line: tok.Line, Column: tok.Column}
+		return &ast.ArrayLiteral{Token: t, Items: items}, true
+
+	case *object.Quote:
+		return obj.Node, true
+
+	default:
+		return nil, false
+	}
+}
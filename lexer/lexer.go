@@ -68,6 +68,10 @@ func (l *Lexer) NextToken() token.Token {
 			l.readChar()
 			tok.Type = token.EQ
 			tok.Literal = "=="
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok.Type = token.ARROW
+			tok.Literal = "=>"
 		} else {
 			tok.Type = token.ASSIGN
 			tok.Literal = string(l.ch)
@@ -83,7 +87,22 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.CONST
 			tok.Literal = "::"
 		} else {
-			tok.Type = token.ILLEGAL
+			tok.Type = token.COLON
+			tok.Literal = string(l.ch)
+		}
+	case '.':
+		if l.peekChar() == '.' {
+			l.readChar()
+			if l.peekChar() == '=' {
+				l.readChar()
+				tok.Type = token.RANGEEQ
+				tok.Literal = "..="
+			} else {
+				tok.Type = token.RANGE
+				tok.Literal = ".."
+			}
+		} else {
+			tok.Type = token.DOT
 			tok.Literal = string(l.ch)
 		}
 	case ';':
@@ -161,8 +180,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok.Type = token.ILLEGAL
@@ -182,12 +200,22 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[start:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or, if a fraction follows its digits, a float.
+// The dot must be followed by a digit, so that 1..2 and 1.abs() are not
+// taken for floats.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	start := l.position
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[start:l.position]
+	if l.ch != '.' || !isDigit(l.peekChar()) {
+		return l.input[start:l.position], token.INT
+	}
+	l.readChar()
+	for isDigit(l.ch) {
+		l.readChar()
+	}
+	return l.input[start:l.position], token.FLOAT
 }
 
 func (l *Lexer) readString() string {
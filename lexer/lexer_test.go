@@ -7,7 +7,7 @@ import (
 )
 
 func TestNextTokenBasic(t *testing.T) {
-	input := `=+(){},;!-/*5<>[]==<=>=:=::`
+	input := `=+(){},;!-/*5<>[]==<=>=:=::=>:..=1..2. 1.5 2.x 3.`
 
 	tests := []struct {
 		expectedType    token.TokenType
@@ -35,6 +35,19 @@ func TestNextTokenBasic(t *testing.T) {
 		{token.GEQ, ">="},
 		{token.DEFINE, ":="},
 		{token.CONST, "::"},
+		{token.ARROW, "=>"},
+		{token.COLON, ":"},
+		{token.RANGEEQ, "..="},
+		{token.INT, "1"},
+		{token.RANGE, ".."},
+		{token.INT, "2"},
+		{token.DOT, "."},
+		{token.FLOAT, "1.5"},
+		{token.INT, "2"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "3"},
+		{token.DOT, "."},
 	}
 
 	l := New(input)
@@ -0,0 +1,290 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrDeadlock      = errors.New("deadlock: all tasks are blocked")
+	ErrClosedChannel = errors.New("channel is closed")
+)
+
+// Scheduler tracks the tasks of one interpreter. All channel operations are
+// serialised through it, which lets a task about to block detect that every
+// other task is blocked as well and fail instead of hanging.
+type Scheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	tasks    int                   // live tasks, including the main one
+	waiters  map[*func() bool]bool // conditions blocked tasks are waiting on
+	deadlock bool
+}
+
+func NewScheduler() *Scheduler {
+	s := &Scheduler{tasks: 1, waiters: map[*func() bool]bool{}}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// wait blocks until ready reports true. It must be called with s.mu held and
+// returns false if the interpreter deadlocked while waiting.
+func (s *Scheduler) wait(ready func() bool) bool {
+	if ready() {
+		return true
+	}
+
+	s.waiters[&ready] = true
+	defer func() {
+		delete(s.waiters, &ready)
+		if len(s.waiters) == 0 {
+			s.deadlock = false
+		}
+	}()
+
+	for !ready() {
+		if s.deadlock || s.stuck() {
+			s.deadlock = true
+			s.cond.Broadcast()
+			return false
+		}
+		s.cond.Wait()
+	}
+
+	return true
+}
+
+// stuck reports whether every task is waiting on a condition that cannot
+// become true, as a waiter that was woken up but not yet rescheduled is still
+// registered.
+func (s *Scheduler) stuck() bool {
+	if len(s.waiters) < s.tasks {
+		return false
+	}
+	for ready := range s.waiters {
+		if (*ready)() {
+			return false
+		}
+	}
+	return true
+}
+
+type Task struct {
+	sched  *Scheduler
+	done   bool
+	result Object
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return "<task>" }
+
+// Spawn runs fn on a new goroutine as a separate task.
+func (s *Scheduler) Spawn(fn func() Object) *Task {
+	t := &Task{sched: s}
+
+	s.mu.Lock()
+	s.tasks += 1
+	s.mu.Unlock()
+
+	go func() {
+		result := fn()
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.result = result
+		t.done = true
+		s.tasks -= 1
+		s.cond.Broadcast()
+	}()
+
+	return t
+}
+
+// Wait blocks until the task finishes and returns its result.
+func (t *Task) Wait() (Object, error) {
+	s := t.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.wait(func() bool { return t.done }) {
+		return nil, ErrDeadlock
+	}
+	return t.result, nil
+}
+
+type Channel struct {
+	sched     *Scheduler
+	capacity  int
+	queue     []Object
+	closed    bool
+	receivers int    // receivers currently waiting
+	sent      uint64 // values ever enqueued
+	taken     uint64 // values ever dequeued
+}
+
+func (s *Scheduler) NewChannel(capacity int) *Channel {
+	return &Channel{sched: s, capacity: capacity}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string {
+	return fmt.Sprintf("<channel %d/%d>", len(c.queue), c.capacity)
+}
+
+func (c *Channel) push(val Object) uint64 {
+	c.queue = append(c.queue, val)
+	c.sent += 1
+	c.sched.cond.Broadcast()
+	return c.sent
+}
+
+func (c *Channel) pop() Object {
+	val := c.queue[0]
+	c.queue = c.queue[1:]
+	c.taken += 1
+	c.sched.cond.Broadcast()
+	return val
+}
+
+// handoff waits until an unbuffered send is picked up by a receiver.
+func (c *Channel) handoff(ticket uint64) error {
+	if c.capacity > 0 {
+		return nil
+	}
+	if !c.sched.wait(func() bool { return c.taken >= ticket }) {
+		return ErrDeadlock
+	}
+	return nil
+}
+
+// Send blocks until val is buffered or, for unbuffered channels, received.
+func (c *Channel) Send(val Object) error {
+	s := c.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ok := s.wait(func() bool {
+		return c.closed || len(c.queue) < c.capacity || len(c.queue) == 0
+	})
+	if !ok {
+		return ErrDeadlock
+	}
+	if c.closed {
+		return ErrClosedChannel
+	}
+
+	return c.handoff(c.push(val))
+}
+
+// Recv blocks until a value is available. It returns false once the channel
+// is closed and drained.
+func (c *Channel) Recv() (Object, bool, error) {
+	s := c.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.receivers += 1
+	s.cond.Broadcast()
+	defer func() { c.receivers -= 1 }()
+
+	if !s.wait(func() bool { return len(c.queue) > 0 || c.closed }) {
+		return nil, false, ErrDeadlock
+	}
+	if len(c.queue) == 0 {
+		return nil, false, nil
+	}
+	return c.pop(), true, nil
+}
+
+// Next receives from the channel, so that channels can be iterated over.
+func (c *Channel) Next() (Object, bool) {
+	val, ok, err := c.Recv()
+	if err != nil {
+		return &Error{Message: err.Error()}, true
+	}
+	return val, ok
+}
+
+func (c *Channel) Close() error {
+	s := c.sched
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.closed {
+		return ErrClosedChannel
+	}
+	c.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+// SelectCase is a single channel operation of a select; Value is nil for
+// receives.
+type SelectCase struct {
+	Channel *Channel
+	Value   Object
+}
+
+func (sc *SelectCase) ready() bool {
+	c := sc.Channel
+	if sc.Value == nil {
+		return len(c.queue) > 0 || c.closed
+	}
+	if c.closed || c.capacity > 0 {
+		return c.closed || len(c.queue) < c.capacity
+	}
+	// an unbuffered send may only commit once someone is there to take it
+	return len(c.queue) == 0 && c.receivers > 0
+}
+
+// Select performs the first case that can proceed. If none can and block is
+// false it returns -1 immediately. For receives it also returns the value
+// and whether the channel was still open.
+func (s *Scheduler) Select(cases []SelectCase, block bool) (int, Object, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chosen := -1
+	ready := func() bool {
+		for i := range cases {
+			if cases[i].Channel.sched == s && cases[i].ready() {
+				chosen = i
+				return true
+			}
+		}
+		return false
+	}
+
+	if !ready() && !block {
+		return -1, nil, false, nil
+	}
+
+	for _, sc := range cases {
+		if sc.Value == nil {
+			sc.Channel.receivers += 1
+		}
+	}
+	s.cond.Broadcast()
+	ok := s.wait(ready)
+	for _, sc := range cases {
+		if sc.Value == nil {
+			sc.Channel.receivers -= 1
+		}
+	}
+	if !ok {
+		return -1, nil, false, ErrDeadlock
+	}
+
+	sc := cases[chosen]
+	if sc.Value == nil {
+		if len(sc.Channel.queue) == 0 {
+			return chosen, nil, false, nil
+		}
+		return chosen, sc.Channel.pop(), true, nil
+	}
+	if sc.Channel.closed {
+		return chosen, nil, false, ErrClosedChannel
+	}
+	return chosen, nil, true, sc.Channel.handoff(sc.Channel.push(sc.Value))
+}
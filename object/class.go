@@ -0,0 +1,147 @@
+package object
+
+import (
+	"strings"
+	"sync"
+)
+
+type Class struct {
+	Name    string
+	Parent  *Class
+	Methods map[string]*Function
+}
+
+func (c *Class) Type() ObjectType { return CLASS_OBJ }
+func (c *Class) Inspect() string {
+	if c.Parent != nil {
+		return "class " + c.Name + " < " + c.Parent.Name
+	}
+	return "class " + c.Name
+}
+
+// Lookup finds the method called name in c or its ancestors and returns it
+// along with the class defining it.
+func (c *Class) Lookup(name string) (*Function, *Class, bool) {
+	for class := c; class != nil; class = class.Parent {
+		if method, ok := class.Methods[name]; ok {
+			return method, class, true
+		}
+	}
+	return nil, nil, false
+}
+
+// IsA reports whether c is other or inherits from it.
+func (c *Class) IsA(other *Class) bool {
+	for class := c; class != nil; class = class.Parent {
+		if class == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Instance is safe for concurrent use by spawned tasks.
+type Instance struct {
+	Class  *Class
+	Frozen bool
+	mu     sync.RWMutex
+	fields map[string]Object
+	// field names in the order they were first assigned
+	order []string
+}
+
+func NewInstance(class *Class) *Instance {
+	return &Instance{Class: class, fields: map[string]Object{}}
+}
+
+func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
+func (i *Instance) Inspect() string  { return InspectWith(i, nil) }
+
+func (i *Instance) inspect(p *printer) string {
+	names, values := i.entries()
+	fields := []string{}
+	for j, name := range names {
+		fields = append(fields, name+": "+inspect(values[j], p))
+	}
+	return i.Class.Name + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// Field returns the field called name or, failing that, the method of that
+// name bound to i.
+func (i *Instance) Field(name string) (Object, bool) {
+	i.mu.RLock()
+	val, ok := i.fields[name]
+	i.mu.RUnlock()
+	if ok {
+		return val, true
+	}
+
+	if method, ok := i.Method(name); ok {
+		return method, true
+	}
+	return nil, false
+}
+
+// Method returns the method called name bound to i.
+func (i *Instance) Method(name string) (*Function, bool) {
+	method, class, ok := i.Class.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return bind(method, class, i), true
+}
+
+// SetField assigns val to the field called name.
+func (i *Instance) SetField(name string, val Object) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.fields[name]; !ok {
+		i.order = append(i.order, name)
+	}
+	i.fields[name] = val
+}
+
+// entries returns the field names of i in order and their values.
+func (i *Instance) entries() ([]string, []Object) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	names := make([]string, len(i.order))
+	values := make([]Object, len(i.order))
+	for j, name := range i.order {
+		names[j] = name
+		values[j] = i.fields[name]
+	}
+	return names, values
+}
+
+// Super looks up methods starting from Class, the parent of the class that
+// defines the running method, bound to Self.
+type Super struct {
+	Self  *Instance
+	Class *Class
+}
+
+func (s *Super) Type() ObjectType { return SUPER_OBJ }
+func (s *Super) Inspect() string  { return "super" }
+
+func (s *Super) Field(name string) (Object, bool) {
+	method, class, ok := s.Class.Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	return bind(method, class, s.Self), true
+}
+
+// bind returns method, defined in class, with self and, if class has a
+// parent, super in scope.
+func bind(method *Function, class *Class, self *Instance) *Function {
+	env := NewEnclosedEnvironment(method.Env)
+	env.Set("self", self)
+	if class.Parent != nil {
+		env.Set("super", &Super{Self: self, Class: class.Parent})
+	}
+
+	bound := *method
+	bound.Env = env
+	return &bound
+}
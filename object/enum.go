@@ -0,0 +1,108 @@
+package object
+
+import "strings"
+
+// Enum is a declared tagged union.
+type Enum struct {
+	Name     string
+	Variants []*Constructor
+}
+
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+func (e *Enum) Inspect() string {
+	variants := []string{}
+	for _, v := range e.Variants {
+		variants = append(variants, v.signature())
+	}
+	return "enum " + e.Name + " { " + strings.Join(variants, ", ") + " }"
+}
+
+// Variant returns the variant of e called name.
+func (e *Enum) Variant(name string) (*Constructor, bool) {
+	for _, v := range e.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Field returns the variant of e called name, so that variants can be
+// qualified as Shape.Circle.
+func (e *Enum) Field(name string) (Object, bool) {
+	v, ok := e.Variant(name)
+	if !ok {
+		return nil, false
+	}
+	if v.Value != nil {
+		return v.Value, true
+	}
+	return v, true
+}
+
+// Constructor creates values of one variant of an enum. Variants without
+// fields have no constructor in scope, only their single Value.
+type Constructor struct {
+	Enum   *Enum
+	Name   string
+	Fields []string
+	// Value is the only value of a variant without fields
+	Value *Variant
+}
+
+func (c *Constructor) Type() ObjectType { return CONSTRUCTOR_OBJ }
+func (c *Constructor) Inspect() string {
+	return "<constructor " + c.Enum.Name + "." + c.signature() + ">"
+}
+
+func (c *Constructor) signature() string {
+	if c.Value != nil {
+		return c.Name
+	}
+	return c.Name + "(" + strings.Join(c.Fields, ", ") + ")"
+}
+
+// Variant is a value of an enum.
+type Variant struct {
+	Constructor *Constructor
+	Values      []Object
+}
+
+func (v *Variant) Type() ObjectType { return VARIANT_OBJ }
+func (v *Variant) Inspect() string  { return InspectWith(v, nil) }
+
+func (v *Variant) inspect(p *printer) string {
+	if v.Constructor.Value != nil {
+		return v.Constructor.Name
+	}
+
+	values := []string{}
+	for _, val := range v.Values {
+		values = append(values, inspect(val, p))
+	}
+	return v.Constructor.Name + "(" + strings.Join(values, ", ") + ")"
+}
+
+// Field returns the value of the field called name.
+func (v *Variant) Field(name string) (Object, bool) {
+	for i, f := range v.Constructor.Fields {
+		if f == name {
+			return v.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// NewEnum creates an enum with the given variants, mapping each variant name
+// to its field names. Variants with nil fields are values.
+func NewEnum(name string, names []string, fields [][]string) *Enum {
+	enum := &Enum{Name: name}
+	for i, n := range names {
+		c := &Constructor{Enum: enum, Name: n, Fields: fields[i]}
+		if fields[i] == nil {
+			c.Value = &Variant{Constructor: c}
+		}
+		enum.Variants = append(enum.Variants, c)
+	}
+	return enum
+}
@@ -1,26 +1,48 @@
 package object
 
+import (
+	"sync"
+
+	"baboon/ast"
+)
+
+// NewEnclosedEnvironment creates an environment sharing the runtime of outer.
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := newEnvironment(outer.runtime)
 	env.outer = outer
 	return env
 }
 
+// NewEnvironment creates a global environment with a runtime of its own.
 func NewEnvironment() *Environment {
+	return newEnvironment(NewRuntime())
+}
+
+func newEnvironment(runtime *Runtime) *Environment {
 	s := make(map[string]Object)
 	// TODO: consider not having two maps
 	c := make(map[string]bool)
-	return &Environment{store: s, consts: c, outer: nil}
+	return &Environment{store: s, consts: c, outer: nil, runtime: runtime}
 }
 
+// Environment is safe for concurrent use by spawned tasks.
 type Environment struct {
+	mu     sync.RWMutex
 	store  map[string]Object
 	consts map[string]bool
 	outer  *Environment
+	yield  func(Object) bool
+	// the body of the generator function whose call this is
+	generating *ast.BlockStatement
+	runtime    *Runtime
+	// deferred calls, non-nil only for the environment of a function call
+	deferred *[]func() Object
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -28,16 +50,103 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.store[name] = val
 	return val
 }
 
 func (e *Environment) SetConst(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	e.store[name] = val
 	e.consts[name] = true
 	return val
 }
 
 func (e *Environment) IsConst(name string) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 	return e.consts[name]
 }
+
+// Runtime returns the state shared by every environment of the interpreter.
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
+// SetYield marks the environment as the one of a call of the generator
+// function with the given body, running in the generator yield belongs to.
+func (e *Environment) SetYield(yield func(Object) bool, body *ast.BlockStatement) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.yield = yield
+	e.generating = body
+}
+
+// Yield returns the yield hook of the innermost enclosing generator.
+func (e *Environment) Yield() (func(Object) bool, bool) {
+	e.mu.RLock()
+	yield := e.yield
+	e.mu.RUnlock()
+	if yield == nil && e.outer != nil {
+		return e.outer.Yield()
+	}
+	return yield, yield != nil
+}
+
+// YieldOf returns the yield hook of the innermost enclosing call of the
+// generator function with the given body, through which recursive calls of
+// the function yield.
+func (e *Environment) YieldOf(body *ast.BlockStatement) (func(Object) bool, bool) {
+	e.mu.RLock()
+	yield, generating := e.yield, e.generating
+	e.mu.RUnlock()
+	if yield != nil && generating == body {
+		return yield, true
+	}
+	if e.outer != nil {
+		return e.outer.YieldOf(body)
+	}
+	return nil, false
+}
+
+// StartCall marks the environment as the one of a function call, which
+// collects the calls deferred by its body.
+func (e *Environment) StartCall() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deferred = &[]func() Object{}
+}
+
+// Defer registers fn with the innermost enclosing function call. It reports
+// false outside of function calls.
+func (e *Environment) Defer(fn func() Object) bool {
+	e.mu.Lock()
+	deferred := e.deferred
+	if deferred != nil {
+		*deferred = append(*deferred, fn)
+	}
+	e.mu.Unlock()
+	if deferred == nil && e.outer != nil {
+		return e.outer.Defer(fn)
+	}
+	return deferred != nil
+}
+
+// Deferred removes and returns the calls deferred in this function call, the
+// most recently deferred first.
+func (e *Environment) Deferred() []func() Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.deferred == nil {
+		return nil
+	}
+
+	calls := []func() Object{}
+	for i := len(*e.deferred) - 1; i >= 0; i-- {
+		calls = append(calls, (*e.deferred)[i])
+	}
+	*e.deferred = nil
+	return calls
+}
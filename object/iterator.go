@@ -0,0 +1,225 @@
+package object
+
+import (
+	"runtime"
+	"sync"
+	"unicode/utf8"
+)
+
+// Iterator produces a sequence of values one at a time. Next returns false
+// once the sequence is exhausted; failures are reported as an *Error value.
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+// Iterable is implemented by objects that can be traversed more than once.
+type Iterable interface {
+	Iter() Iterator
+}
+
+// Iterate returns an iterator over obj, which must be an Iterator or Iterable.
+func Iterate(obj Object) (Iterator, bool) {
+	switch obj := obj.(type) {
+	case Iterator:
+		return obj, true
+	case Iterable:
+		return obj.Iter(), true
+	default:
+		return nil, false
+	}
+}
+
+type arrayIterator struct {
+	items []Object
+	pos   int
+}
+
+func (ai *arrayIterator) Next() (Object, bool) {
+	if ai.pos >= len(ai.items) {
+		return nil, false
+	}
+	item := ai.items[ai.pos]
+	ai.pos += 1
+	return item, true
+}
+
+func (al *ArrayLiteral) Iter() Iterator {
+	return &arrayIterator{items: al.Elements()}
+}
+
+type stringIterator struct {
+	value string
+}
+
+func (si *stringIterator) Next() (Object, bool) {
+	r, size := utf8.DecodeRuneInString(si.value)
+	if size == 0 {
+		return nil, false
+	}
+	si.value = si.value[size:]
+	return &String{Value: string(r)}, true
+}
+
+func (s *String) Iter() Iterator {
+	return &stringIterator{value: s.Value}
+}
+
+// Closer is implemented by iterators holding on to resources until they are
+// exhausted. Consumers that stop early close them.
+type Closer interface {
+	Close()
+}
+
+// Stop closes it if it is a Closer.
+func Stop(it Iterator) {
+	if c, ok := it.(Closer); ok {
+		c.Close()
+	}
+}
+
+// Generator runs a function body on its own goroutine, suspending it after
+// every yielded value until the next call to Next. Generators dropped before
+// they are exhausted release their goroutine once closed, either by their
+// consumer or their runtime, or garbage collected.
+type Generator struct {
+	*generator
+}
+
+// generator is kept separate from Generator so that the running goroutine
+// does not keep the Generator itself reachable.
+type generator struct {
+	body     func(yield func(Object) bool) Object
+	mu       sync.Mutex // held by Next, as tasks may share a generator
+	started  bool
+	done     bool
+	values   chan Object
+	resume   chan struct{}
+	abandon  chan struct{}
+	finished chan struct{}
+	once     sync.Once
+	result   Object
+	// the generators running on behalf of the same runtime, if any
+	set *generatorSet
+}
+
+// NewGenerator creates a generator whose body is started lazily by the first
+// call to Next. yield blocks until the value is consumed and the generator
+// resumed; it returns false if the generator was abandoned, in which case the
+// body should unwind and return.
+func NewGenerator(body func(yield func(Object) bool) Object) *Generator {
+	g := &Generator{&generator{
+		body:     body,
+		values:   make(chan Object),
+		resume:   make(chan struct{}),
+		abandon:  make(chan struct{}),
+		finished: make(chan struct{}),
+	}}
+	runtime.SetFinalizer(g, func(g *Generator) { g.Close() })
+	return g
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string  { return "<generator>" }
+
+func (g *Generator) Next() (Object, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done {
+		return nil, false
+	}
+
+	select {
+	case <-g.abandon:
+		g.done = true
+		return nil, false
+	default:
+	}
+
+	if !g.started {
+		g.started = true
+		if g.set != nil {
+			g.set.add(g.generator)
+		}
+		go g.run()
+	} else {
+		g.resume <- struct{}{}
+	}
+
+	select {
+	case val := <-g.values:
+		return val, true
+	case <-g.finished:
+		g.done = true
+		if err, isErr := g.result.(*Error); isErr {
+			return err, true
+		}
+		return nil, false
+	}
+}
+
+// Close abandons the generator, unwinding its body if it is suspended.
+func (g *generator) Close() {
+	g.once.Do(func() { close(g.abandon) })
+}
+
+func (g *generator) run() {
+	defer close(g.finished)
+	if g.set != nil {
+		defer g.set.remove(g)
+	}
+
+	g.result = g.body(func(val Object) bool {
+		select {
+		case g.values <- val:
+		case <-g.abandon:
+			return false
+		case <-g.finished:
+			// a closure created by the generator outlived it
+			return false
+		}
+		select {
+		case <-g.resume:
+			return true
+		case <-g.abandon:
+			return false
+		}
+	})
+}
+
+// generatorSet tracks running generators so that they can be closed
+// together. It refers to the part of a Generator its goroutine does, so that
+// dropped generators can still be garbage collected.
+type generatorSet struct {
+	mu      sync.Mutex
+	running map[*generator]bool
+}
+
+func (s *generatorSet) add(g *generator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running == nil {
+		s.running = map[*generator]bool{}
+	}
+	s.running[g] = true
+}
+
+func (s *generatorSet) remove(g *generator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, g)
+}
+
+// closeAll closes every running generator.
+func (s *generatorSet) closeAll() {
+	s.mu.Lock()
+	running := make([]*generator, 0, len(s.running))
+	for g := range s.running {
+		running = append(running, g)
+	}
+	s.mu.Unlock()
+
+	for _, g := range running {
+		g.Close()
+	}
+}
@@ -0,0 +1,94 @@
+package object
+
+import (
+	"strings"
+	"sync"
+)
+
+// Map maps strings to values, remembering the order in which keys were
+// first set. It is safe for concurrent use by spawned tasks.
+type Map struct {
+	Frozen bool
+	mu     sync.RWMutex
+	pairs  map[string]Object
+	keys   []string
+}
+
+func NewMap() *Map {
+	return &Map{pairs: map[string]Object{}}
+}
+
+func (m *Map) Type() ObjectType { return MAP_OBJ }
+func (m *Map) Inspect() string  { return InspectWith(m, nil) }
+
+func (m *Map) inspect(p *printer) string {
+	var out strings.Builder
+
+	keys, values := m.entries()
+	pairs := []string{}
+	for i, key := range keys {
+		pairs = append(pairs, (&String{Value: key}).Inspect()+": "+inspect(values[i], p))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (m *Map) Get(key string) (Object, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	val, ok := m.pairs[key]
+	return val, ok
+}
+
+func (m *Map) Set(key string, val Object) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pairs[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.pairs[key] = val
+}
+
+// Keys returns the keys of m in insertion order.
+func (m *Map) Keys() []string {
+	keys, _ := m.entries()
+	return keys
+}
+
+func (m *Map) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys)
+}
+
+// entries returns the keys of m in insertion order and their values.
+func (m *Map) entries() ([]string, []Object) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]string, len(m.keys))
+	values := make([]Object, len(m.keys))
+	for i, key := range m.keys {
+		keys[i] = key
+		values[i] = m.pairs[key]
+	}
+	return keys, values
+}
+
+// Field makes keys accessible as fields.
+func (m *Map) Field(name string) (Object, bool) {
+	return m.Get(name)
+}
+
+// Iter iterates over the keys of m as they were when it was created.
+func (m *Map) Iter() Iterator {
+	keys := m.Keys()
+	items := make([]Object, len(keys))
+	for i, key := range keys {
+		items[i] = &String{Value: key}
+	}
+	return &arrayIterator{items: items}
+}
@@ -2,7 +2,9 @@ package object
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
 	"baboon/ast"
 	"baboon/token"
@@ -12,6 +14,7 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ  = "INTEGER"
+	FLOAT_OBJ    = "FLOAT"
 	STRING_OBJ   = "STRING"
 	BOOLEAN_OBJ  = "BOOLEAN"
 	VOID_OBJ     = "VOID"
@@ -20,6 +23,24 @@ const (
 	FUNCTION_OBJ = "FUNCTION"
 	BUILTIN_OBJ  = "BUILTIN"
 	ARRAY_OBJ    = "ARRAY"
+
+	GENERATOR_OBJ = "GENERATOR"
+	TASK_OBJ      = "TASK"
+	CHANNEL_OBJ   = "CHANNEL"
+	QUOTE_OBJ     = "QUOTE"
+	MACRO_OBJ     = "MACRO"
+
+	ENUM_OBJ        = "ENUM"
+	CONSTRUCTOR_OBJ = "CONSTRUCTOR"
+	VARIANT_OBJ     = "VARIANT"
+	RANGE_OBJ       = "RANGE"
+	CLASS_OBJ       = "CLASS"
+	INSTANCE_OBJ    = "INSTANCE"
+	SUPER_OBJ       = "SUPER"
+	USER_TYPE_OBJ   = "TYPE"
+	TYPED_OBJ       = "TYPED"
+	MAP_OBJ         = "MAP"
+	REGEX_OBJ       = "REGEX"
 )
 
 type Object interface {
@@ -27,6 +48,63 @@ type Object interface {
 	Inspect() string
 }
 
+// Fielder is implemented by objects with named fields, which dot expressions
+// look up before falling back to functions in scope.
+type Fielder interface {
+	Field(name string) (Object, bool)
+}
+
+// Displayer is implemented by objects whose display form, printed for
+// users, differs from the debug form returned by Inspect.
+type Displayer interface {
+	Display() string
+}
+
+// container is implemented by objects that may contain themselves, whose
+// Inspect goes through inspect.
+type container interface {
+	inspect(p *printer) string
+}
+
+// printer holds the state of printing a value along with the values it
+// contains.
+type printer struct {
+	// containers being printed already, which are printed as <cycle>
+	seen map[Object]bool
+	show func(Object) (string, bool)
+}
+
+// InspectWith returns the debug form of obj, using show for obj and every
+// value it contains before falling back to their own debug form.
+func InspectWith(obj Object, show func(Object) (string, bool)) string {
+	return inspect(obj, &printer{seen: map[Object]bool{}, show: show})
+}
+
+// inspect returns the debug form of obj as printed by p.
+func inspect(obj Object, p *printer) string {
+	if p.show != nil {
+		if s, ok := p.show(obj); ok {
+			return s
+		}
+	}
+	c, ok := obj.(container)
+	if !ok {
+		return obj.Inspect()
+	}
+	if p.seen[obj] {
+		return "<cycle>"
+	}
+	p.seen[obj] = true
+	defer delete(p.seen, obj)
+	return c.inspect(p)
+}
+
+// Methoder is implemented by user defined objects, whose methods named after
+// protocols like __len or __str are used by builtins and operators.
+type Methoder interface {
+	Method(name string) (*Function, bool)
+}
+
 type Integer struct {
 	Value int64
 }
@@ -34,12 +112,29 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprint(i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect prints whole floats with a fraction, so that they are not taken
+// for integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+	return s + ".0"
+}
+
 type String struct {
 	Value string
 }
 
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return "\"" + s.Value + "\"" }
+func (s *String) Display() string  { return s.Value }
 
 type Boolean struct {
 	Value bool
@@ -57,11 +152,20 @@ type Error struct {
 	Message string
 	Line    int
 	Column  int
+	// errors raised by deferred calls while this one propagated
+	Deferred []*Error
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string {
-	return fmt.Sprintf("[%d:%d] %s", e.Line, e.Column, e.Message)
+	var out strings.Builder
+
+	out.WriteString(fmt.Sprintf("[%d:%d] %s", e.Line, e.Column, e.Message))
+	for _, d := range e.Deferred {
+		out.WriteString("\ndeferred: " + d.Inspect())
+	}
+
+	return out.String()
 }
 
 type Return struct {
@@ -72,9 +176,11 @@ func (r *Return) Type() ObjectType { return RETURN_OBJ }
 func (r *Return) Inspect() string  { return r.Value.Inspect() }
 
 type Function struct {
+	Name       string // empty for function literals
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -86,7 +192,11 @@ func (f *Function) Inspect() string {
 		params = append(params, p.String())
 	}
 
-	out.WriteString("fn(")
+	out.WriteString("fn")
+	if f.Name != "" {
+		out.WriteString(" " + f.Name)
+	}
+	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
@@ -95,7 +205,38 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
-type BuiltinFunction func(token *token.Token, args ...Object) Object
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "quote(" + q.Node.String() + ")" }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out strings.Builder
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+type BuiltinFunction func(env *Environment, token *token.Token, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -104,18 +245,51 @@ type Builtin struct {
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "<builtin function>" }
 
+// ArrayLiteral is safe for concurrent use by spawned tasks. Items is set
+// when it is created, which is also when its length is fixed; afterwards
+// items are read and written through Get, Set and Elements.
 type ArrayLiteral struct {
-	Items []Object
-	// TODO: add length
+	Items  []Object
+	Frozen bool
+	mu     sync.RWMutex
 }
 
 func (al *ArrayLiteral) Type() ObjectType { return ARRAY_OBJ }
-func (al *ArrayLiteral) Inspect() string {
+func (al *ArrayLiteral) Inspect() string  { return InspectWith(al, nil) }
+
+func (al *ArrayLiteral) Len() int {
+	return len(al.Items)
+}
+
+// Get returns the item at index i, which must be within bounds.
+func (al *ArrayLiteral) Get(i int) Object {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	return al.Items[i]
+}
+
+// Set replaces the item at index i, which must be within bounds.
+func (al *ArrayLiteral) Set(i int, val Object) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	al.Items[i] = val
+}
+
+// Elements returns a copy of the items of al.
+func (al *ArrayLiteral) Elements() []Object {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+	items := make([]Object, len(al.Items))
+	copy(items, al.Items)
+	return items
+}
+
+func (al *ArrayLiteral) inspect(p *printer) string {
 	var out strings.Builder
 
 	items := []string{}
-	for _, i := range al.Items {
-		items = append(items, i.Inspect())
+	for _, i := range al.Elements() {
+		items = append(items, inspect(i, p))
 	}
 
 	out.WriteString("[")
@@ -124,3 +298,141 @@ func (al *ArrayLiteral) Inspect() string {
 
 	return out.String()
 }
+
+// Freeze returns obj made immutable. Mutable containers are copied, along
+// with every mutable container reachable from them, so that other references
+// to obj can still mutate it. Frozen containers are shared.
+func Freeze(obj Object) Object {
+	return freeze(obj, map[Object]Object{})
+}
+
+// freeze returns the frozen copy of obj, reusing the copies in copies, which
+// maps the containers copied so far to their copies.
+func freeze(obj Object, copies map[Object]Object) Object {
+	if c, ok := copies[obj]; ok {
+		return c
+	}
+
+	switch obj := obj.(type) {
+	case *ArrayLiteral:
+		if obj.Frozen {
+			return obj
+		}
+		items := obj.Elements()
+		c := &ArrayLiteral{Items: make([]Object, len(items)), Frozen: true}
+		copies[obj] = c
+		for i, item := range items {
+			c.Items[i] = freeze(item, copies)
+		}
+		return c
+	case *Variant:
+		if len(obj.Values) == 0 {
+			return obj
+		}
+		c := &Variant{Constructor: obj.Constructor, Values: make([]Object, len(obj.Values))}
+		copies[obj] = c
+		for i, val := range obj.Values {
+			c.Values[i] = freeze(val, copies)
+		}
+		return c
+	case *Typed:
+		c := &Typed{UserType: obj.UserType}
+		copies[obj] = c
+		c.Value = freeze(obj.Value, copies)
+		return c
+	case *Instance:
+		if obj.Frozen {
+			return obj
+		}
+		c := NewInstance(obj.Class)
+		c.Frozen = true
+		copies[obj] = c
+		names, values := obj.entries()
+		for i, name := range names {
+			c.SetField(name, freeze(values[i], copies))
+		}
+		return c
+	case *Map:
+		if obj.Frozen {
+			return obj
+		}
+		c := NewMap()
+		c.Frozen = true
+		copies[obj] = c
+		keys, values := obj.entries()
+		for i, key := range keys {
+			c.Set(key, freeze(values[i], copies))
+		}
+		return c
+	}
+	return obj
+}
+
+// Equal reports whether a and b are equal. Scalars, variants, ranges and
+// instances of the same class are compared by value, everything else by
+// identity. Instances referring back to instances being compared are equal
+// if nothing else differs.
+func Equal(a Object, b Object) bool {
+	return equal(a, b, map[[2]Object]bool{})
+}
+
+// equal compares a and b, assuming the pairs of instances in seen, which
+// are being compared already, to be equal.
+func equal(a Object, b Object, seen map[[2]Object]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Variant:
+		b, ok := b.(*Variant)
+		if !ok || a.Constructor != b.Constructor {
+			return false
+		}
+		for i := range a.Values {
+			if !equal(a.Values[i], b.Values[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *Range:
+		b, ok := b.(*Range)
+		return ok && *a == *b
+	case *Instance:
+		b, ok := b.(*Instance)
+		if !ok || a.Class != b.Class {
+			return false
+		}
+		pair := [2]Object{a, b}
+		if a == b || seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		names, values := a.entries()
+		otherNames, otherValues := b.entries()
+		if len(names) != len(otherNames) {
+			return false
+		}
+		others := make(map[string]Object, len(otherNames))
+		for i, name := range otherNames {
+			others[name] = otherValues[i]
+		}
+		for i, name := range names {
+			other, ok := others[name]
+			if !ok || !equal(values[i], other, seen) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
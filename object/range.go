@@ -0,0 +1,120 @@
+package object
+
+import (
+	"fmt"
+	"math"
+)
+
+// Range is a lazy sequence of integers from Start towards End, counting by
+// Step. End is excluded unless Inclusive. Elements are computed on demand.
+type Range struct {
+	Start     int64
+	End       int64
+	Step      int64
+	Inclusive bool
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	op := ".."
+	if r.Inclusive {
+		op = "..="
+	}
+	if r.Step != 1 {
+		return fmt.Sprintf("%d%s%d by %d", r.Start, op, r.End, r.Step)
+	}
+	return fmt.Sprintf("%d%s%d", r.Start, op, r.End)
+}
+
+// last returns the index of the last element of r, reporting false if r is
+// empty. Indices are unsigned, since a range may have more elements than an
+// int64 can count.
+func (r *Range) last() (uint64, bool) {
+	var span, step uint64
+	switch {
+	case r.Step > 0 && (r.End > r.Start || r.Inclusive && r.End == r.Start):
+		span, step = uint64(r.End)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && (r.End < r.Start || r.Inclusive && r.End == r.Start):
+		span, step = uint64(r.Start)-uint64(r.End), uint64(-r.Step)
+	default:
+		return 0, false
+	}
+
+	if !r.Inclusive {
+		span -= 1
+	}
+	return span / step, true
+}
+
+// Len returns the number of elements in r, reporting false if it is greater
+// than the largest int64.
+func (r *Range) Len() (int64, bool) {
+	last, ok := r.last()
+	switch {
+	case !ok:
+		return 0, true
+	case last >= math.MaxInt64:
+		return 0, false
+	default:
+		return int64(last) + 1, true
+	}
+}
+
+// at returns the element at index i, which must be within bounds.
+func (r *Range) at(i uint64) int64 {
+	return int64(uint64(r.Start) + i*uint64(r.Step))
+}
+
+// Index returns the element at index i, counting from the end if i is
+// negative. It reports false if i is out of bounds.
+func (r *Range) Index(i int64) (int64, bool) {
+	last, ok := r.last()
+	if !ok {
+		return 0, false
+	}
+	if i >= 0 {
+		return r.at(uint64(i)), uint64(i) <= last
+	}
+	back := uint64(-i) - 1
+	return r.at(last - back), back <= last
+}
+
+// Contains reports whether n is an element of r.
+func (r *Range) Contains(n int64) bool {
+	var offset, step uint64
+	switch {
+	case r.Step > 0 && n >= r.Start:
+		offset, step = uint64(n)-uint64(r.Start), uint64(r.Step)
+	case r.Step < 0 && n <= r.Start:
+		offset, step = uint64(r.Start)-uint64(n), uint64(-r.Step)
+	default:
+		return false
+	}
+
+	last, ok := r.last()
+	return ok && offset%step == 0 && offset/step <= last
+}
+
+type rangeIterator struct {
+	r    *Range
+	pos  uint64
+	last uint64
+	done bool
+}
+
+func (ri *rangeIterator) Next() (Object, bool) {
+	if ri.done {
+		return nil, false
+	}
+	n := ri.r.at(ri.pos)
+	if ri.pos == ri.last {
+		ri.done = true
+	}
+	ri.pos += 1
+	return &Integer{Value: n}, true
+}
+
+func (r *Range) Iter() Iterator {
+	last, ok := r.last()
+	return &rangeIterator{r: r, last: last, done: !ok}
+}
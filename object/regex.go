@@ -0,0 +1,13 @@
+package object
+
+import "regexp"
+
+// Regex is a compiled regular expression in the syntax of Go's RE2 engine.
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+func (r *Regex) Type() ObjectType { return REGEX_OBJ }
+func (r *Regex) Inspect() string {
+	return "regex(" + (&String{Value: r.Regexp.String()}).Inspect() + ")"
+}
@@ -0,0 +1,198 @@
+package object
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runtime holds the state of one interpreter instance. It is created along
+// with the global environment and shared by all environments enclosed in it,
+// including those of spawned tasks.
+type Runtime struct {
+	Scheduler *Scheduler
+	// Root is the directory file builtins are confined to. File access is
+	// disabled while it is empty.
+	Root string
+	// Stdout is written to by print.
+	Stdout io.Writer
+	// Clock is the source of time for the time builtins.
+	Clock Clock
+
+	stdinMu sync.Mutex
+	stdin   *bufio.Reader
+
+	startOnce sync.Once
+	start     time.Time
+
+	randMu sync.Mutex
+	// created on first use, seeded from Clock
+	rand *rand.Rand
+
+	generators generatorSet
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Scheduler: NewScheduler(),
+		Stdout:    os.Stdout,
+		stdin:     bufio.NewReader(os.Stdin),
+		Clock:     SystemClock{},
+	}
+}
+
+// Seed makes the random source repeat the sequence it produces for seed.
+func (r *Runtime) Seed(seed int64) {
+	r.randMu.Lock()
+	defer r.randMu.Unlock()
+	r.source().Seed(seed)
+}
+
+// Random calls fn with the random source of the runtime, holding a lock so
+// that tasks take turns using it.
+func (r *Runtime) Random(fn func(rnd *rand.Rand)) {
+	r.randMu.Lock()
+	defer r.randMu.Unlock()
+	fn(r.source())
+}
+
+// source returns the random source, seeding it from Clock on first use so
+// that an injected clock makes a run reproducible. randMu must be held.
+func (r *Runtime) source() *rand.Rand {
+	if r.rand == nil {
+		r.rand = rand.New(rand.NewSource(r.Clock.Now().UnixNano()))
+	}
+	return r.rand
+}
+
+// Elapsed returns the time passed on Clock since Elapsed was first called.
+// It is measured on the monotonic clock where the system clock is used.
+func (r *Runtime) Elapsed() time.Duration {
+	r.startOnce.Do(func() { r.start = r.Clock.Now() })
+	return r.Clock.Now().Sub(r.start)
+}
+
+// NewGenerator creates a generator closed along with the runtime.
+func (r *Runtime) NewGenerator(body func(yield func(Object) bool) Object) *Generator {
+	g := NewGenerator(body)
+	g.set = &r.generators
+	return g
+}
+
+// Close closes the generators still running, ending their goroutines. Hosts
+// call it once the program or session using the runtime has ended.
+func (r *Runtime) Close() {
+	r.generators.closeAll()
+}
+
+// SetStdin replaces the reader the input builtins read from.
+func (r *Runtime) SetStdin(in io.Reader) {
+	r.stdinMu.Lock()
+	defer r.stdinMu.Unlock()
+	r.stdin = bufio.NewReader(in)
+}
+
+// ReadLine reads the next line of input without its line ending. It returns
+// io.EOF only if the input has ended before the line started.
+func (r *Runtime) ReadLine() (string, error) {
+	r.stdinMu.Lock()
+	defer r.stdinMu.Unlock()
+
+	line, err := r.stdin.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return line, err
+}
+
+// ReadAll reads the rest of the input.
+func (r *Runtime) ReadAll() (string, error) {
+	r.stdinMu.Lock()
+	defer r.stdinMu.Unlock()
+
+	data, err := io.ReadAll(r.stdin)
+	return string(data), err
+}
+
+var (
+	ErrFileAccessDisabled = errors.New("file access is disabled")
+	ErrOutsideRoot        = errors.New("path outside of the allowed directory")
+	ErrRoot               = errors.New("path is the allowed directory itself")
+)
+
+// Resolve returns the host path of path, which is relative to Root. Paths
+// leading outside of Root, also by following symbolic links, are rejected,
+// as are links that do not resolve.
+func (r *Runtime) Resolve(path string) (string, error) {
+	if r.Root == "" {
+		return "", ErrFileAccessDisabled
+	}
+
+	root, err := filepath.Abs(r.Root)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(path) {
+		return "", ErrOutsideRoot
+	}
+	full := filepath.Join(root, path)
+	if !within(root, full) {
+		return "", ErrOutsideRoot
+	}
+
+	// the longest existing prefix must not be a link leading elsewhere
+	existing, rest := full, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !within(root, resolved) {
+				return "", ErrOutsideRoot
+			}
+			return filepath.Join(resolved, rest), nil
+		}
+		if existing == root {
+			return "", err
+		}
+		if _, err := os.Lstat(existing); err == nil {
+			// a dangling link could be created outside
+			return "", ErrOutsideRoot
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+	}
+}
+
+// ResolveEntry is Resolve for paths naming an entry of Root, rejecting Root
+// itself.
+func (r *Runtime) ResolveEntry(path string) (string, error) {
+	full, err := r.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := r.Resolve(".")
+	if err != nil {
+		return "", err
+	}
+	if full == root {
+		return "", ErrRoot
+	}
+	return full, nil
+}
+
+func within(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,51 @@
+package object
+
+// UserType is a method table created by the type builtin. Calling it wraps a
+// value in a Typed whose methods may implement protocols such as __len.
+type UserType struct {
+	Name    string
+	Methods map[string]*Function
+}
+
+func (ut *UserType) Type() ObjectType { return USER_TYPE_OBJ }
+func (ut *UserType) Inspect() string  { return "type " + ut.Name }
+
+// Typed is a value with the methods of a user type attached.
+type Typed struct {
+	UserType *UserType
+	Value    Object
+}
+
+func (t *Typed) Type() ObjectType { return TYPED_OBJ }
+func (t *Typed) Inspect() string  { return InspectWith(t, nil) }
+
+func (t *Typed) inspect(p *printer) string {
+	return t.UserType.Name + "(" + inspect(t.Value, p) + ")"
+}
+
+// Field returns the wrapped value as value or, failing that, the method
+// called name bound to t.
+func (t *Typed) Field(name string) (Object, bool) {
+	if name == "value" {
+		return t.Value, true
+	}
+	if method, ok := t.Method(name); ok {
+		return method, true
+	}
+	return nil, false
+}
+
+// Method returns the method called name with self bound to t.
+func (t *Typed) Method(name string) (*Function, bool) {
+	method, ok := t.UserType.Methods[name]
+	if !ok {
+		return nil, false
+	}
+
+	env := NewEnclosedEnvironment(method.Env)
+	env.Set("self", t)
+
+	bound := *method
+	bound.Env = env
+	return &bound, true
+}
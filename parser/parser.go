@@ -16,6 +16,9 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
+	// enclosing function literals, innermost last
+	functions []*ast.FunctionExpression
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -26,6 +29,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.prefixParseFns[token.IDENT] = p.parseIdentifier
 	p.prefixParseFns[token.INT] = p.parseIntegerLiteral
+	p.prefixParseFns[token.FLOAT] = p.parseFloatLiteral
 	p.prefixParseFns[token.STRING] = p.parseStringLiteral
 	p.prefixParseFns[token.TRUE] = p.parseBoolean
 	p.prefixParseFns[token.FALSE] = p.parseBoolean
@@ -34,7 +38,16 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns[token.LPAREN] = p.parseGroupedExpression
 	p.prefixParseFns[token.IF] = p.parseIfExpression
 	p.prefixParseFns[token.FUNCTION] = p.parseFunctionExpression
+	p.prefixParseFns[token.YIELD] = p.parseYieldExpression
+	p.prefixParseFns[token.SPAWN] = p.parseSpawnExpression
+	p.prefixParseFns[token.SELECT] = p.parseSelectExpression
+	p.prefixParseFns[token.MACRO] = p.parseMacroLiteral
+	p.prefixParseFns[token.ENUM] = p.parseEnumLiteral
+	p.prefixParseFns[token.CASE] = p.parseCaseExpression
+	p.prefixParseFns[token.CLASS] = p.parseClassLiteral
 	p.prefixParseFns[token.LBRACKET] = p.parseArrayLiteral
+	// braces starting a statement are blocks
+	p.prefixParseFns[token.LBRACE] = p.parseMapLiteral
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.infixParseFns[token.PLUS] = p.parseInfixExpression
@@ -47,8 +60,12 @@ func New(l *lexer.Lexer) *Parser {
 	p.infixParseFns[token.GT] = p.parseInfixExpression
 	p.infixParseFns[token.LEQ] = p.parseInfixExpression
 	p.infixParseFns[token.GEQ] = p.parseInfixExpression
+	p.infixParseFns[token.IN] = p.parseInfixExpression
+	p.infixParseFns[token.RANGE] = p.parseRangeExpression
+	p.infixParseFns[token.RANGEEQ] = p.parseRangeExpression
 	p.infixParseFns[token.LPAREN] = p.parseCallExpression
 	p.infixParseFns[token.LBRACKET] = p.parseAccessExpression
+	p.infixParseFns[token.DOT] = p.parseDotExpression
 	p.infixParseFns[token.DEFINE] = p.parseAssignExpression
 	p.infixParseFns[token.ASSIGN] = p.parseAssignExpression
 	p.infixParseFns[token.CONST] = p.parseAssignExpression
@@ -100,13 +117,40 @@ func (p *Parser) parseStatement() (ast.Statement, bool) {
 	switch p.curToken.Type {
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
 	case token.LBRACE:
+		if p.opensMapLiteral() {
+			return p.parseExpressionStatement()
+		}
 		return p.parseBlockStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionDeclaration()
+		}
+		return p.parseExpressionStatement()
+	case token.IDENT:
+		if p.peekTokenIs(token.COLON) {
+			return p.parseAnnotatedBinding()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+// opensMapLiteral reports whether the brace at the current token opens a map
+// literal rather than a block, as it does when followed by a string and a
+// colon. Other keys, such as names followed by a type annotation, are taken
+// to start a block.
+func (p *Parser) opensMapLiteral() bool {
+	if !p.peekTokenIs(token.STRING) {
+		return false
+	}
+	l := *p.l
+	return l.NextToken().Type == token.COLON
+}
+
 func (p *Parser) parseReturnStatement() (*ast.ReturnStatement, bool) {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -126,6 +170,114 @@ func (p *Parser) parseReturnStatement() (*ast.ReturnStatement, bool) {
 	return stmt, true
 }
 
+func (p *Parser) parseDeferStatement() (*ast.DeferStatement, bool) {
+	stmt := &ast.DeferStatement{Token: p.curToken}
+
+	if len(p.functions) == 0 {
+		msg := fmt.Sprintf("[%d:%d] defer outside of function", p.curToken.Line, p.curToken.Column)
+		p.errors = append(p.errors, msg)
+		return nil, false
+	}
+
+	p.nextToken()
+
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		return nil, false
+	}
+	stmt.Value = value
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, true
+}
+
+// parseAnnotatedBinding parses `name: type := value` and `name: type :: value`.
+func (p *Parser) parseAnnotatedBinding() (*ast.ExpressionStatement, bool) {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken() // eat IDENT
+	p.nextToken() // eat COLON
+
+	name.Type = p.parseTypeExpression()
+	if name.Type == nil {
+		return nil, false
+	}
+
+	if !p.peekTokenIs(token.DEFINE) && !p.peekTokenIs(token.CONST) {
+		pt := p.peekToken
+		msg := fmt.Sprintf("[%d:%d] expected := or :: after type annotation, got %q instead", pt.Line, pt.Column, pt.Type)
+		p.errors = append(p.errors, msg)
+		return nil, false
+	}
+	p.nextToken()
+
+	exp, ok := p.parseAssignExpression(name).(*ast.AssignExpression)
+	if !ok {
+		return nil, false
+	}
+	stmt.Expression = exp
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt, true
+}
+
+func (p *Parser) parseTypeExpression() *ast.TypeExpression {
+	te := &ast.TypeExpression{Token: p.curToken, Name: p.curToken.Literal}
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		return te
+	case token.LBRACKET:
+		p.nextToken()
+		te.Element = p.parseTypeExpression()
+		if te.Element == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		te.Name = "[]"
+		return te
+	case token.FUNCTION:
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		te.Parameters = []*ast.TypeExpression{}
+		for !p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			param := p.parseTypeExpression()
+			if param == nil {
+				return nil
+			}
+			te.Parameters = append(te.Parameters, param)
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			te.Return = p.parseTypeExpression()
+			if te.Return == nil {
+				return nil
+			}
+		}
+		return te
+	default:
+		msg := fmt.Sprintf("[%d:%d] expected type, got %q instead", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+}
+
 func (p *Parser) parseExpressionStatement() (*ast.ExpressionStatement, bool) {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -203,22 +355,126 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		msg := fmt.Sprintf("[%d:%d] could not parse %q as float", p.curToken.Line, p.curToken.Column, p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
 func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
-	array := &ast.ArrayLiteral{Token: p.curToken}
+	array := &ast.ArrayLiteral{Token: p.curToken, Items: []ast.Expression{}}
 
-	items := p.parseExpressionsList(token.RBRACKET)
-	if items == nil {
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return array
+	}
+
+	p.nextToken()
+
+	first := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.FOR) {
+		return p.parseComprehension(array.Token, first)
+	}
+
+	array.Items = append(array.Items, first)
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		array.Items = append(array.Items, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
-	array.Items = items
 
 	return array
 }
 
+func (p *Parser) parseMapLiteral() ast.Expression {
+	m := &ast.MapLiteral{Token: p.curToken, Keys: []ast.Expression{}, Values: []ast.Expression{}}
+
+	if p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		return m
+	}
+
+	for {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		m.Keys = append(m.Keys, key)
+		m.Values = append(m.Values, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return m
+}
+
+// parseComprehension parses the rest of `[element for name in iterable if
+// condition]` after the element.
+func (p *Parser) parseComprehension(tok token.Token, element ast.Expression) ast.Expression {
+	exp := &ast.Comprehension{Token: tok, Element: element}
+	if element == nil {
+		return nil
+	}
+
+	p.nextToken() // eat FOR
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Iterable = p.parseExpression(LOWEST)
+	if exp.Iterable == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		exp.Condition = p.parseExpression(LOWEST)
+		if exp.Condition == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curToken.Type == token.TRUE}
 }
@@ -297,6 +553,60 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 
 	exp.Parameters = p.parseFunctionParameters()
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		exp.ReturnType = p.parseTypeExpression()
+		if exp.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	p.functions = append(p.functions, exp)
+	body, ok := p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
+	if !ok {
+		return nil
+	}
+	exp.Body = body
+
+	return exp
+}
+
+// parseFunctionDeclaration parses `fn name(params) { body }`.
+func (p *Parser) parseFunctionDeclaration() (*ast.FunctionDeclaration, bool) {
+	decl := &ast.FunctionDeclaration{Token: p.curToken}
+
+	p.nextToken()
+	decl.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	fn, ok := p.parseFunctionExpression().(*ast.FunctionExpression)
+	if !ok {
+		return nil, false
+	}
+	fn.Token = decl.Token
+	decl.Function = fn
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return decl, true
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	exp := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	exp.Parameters = p.parseFunctionParameters()
+
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -310,6 +620,29 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+
+	if len(p.functions) == 0 {
+		msg := fmt.Sprintf("[%d:%d] yield outside of function", p.curToken.Line, p.curToken.Column)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	p.functions[len(p.functions)-1].Generator = true
+
+	p.nextToken()
+
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		msg := fmt.Sprintf("[%d:%d] couldn't parse yielded expression", exp.Token.Line, exp.Token.Column)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	exp.Value = value
+
+	return exp
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	params := []*ast.Identifier{}
 
@@ -320,15 +653,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	p.nextToken() // eat LPAREN
 
-	param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	params = append(params, param)
+	params = append(params, p.parseParameter())
 
 	for p.peekToken.Type == token.COMMA {
 		p.nextToken()
 		p.nextToken()
 
-		param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		params = append(params, param)
+		params = append(params, p.parseParameter())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
@@ -338,6 +669,18 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return params
 }
 
+func (p *Parser) parseParameter() *ast.Identifier {
+	param := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		param.Type = p.parseTypeExpression()
+	}
+
+	return param
+}
+
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	expression := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -358,6 +701,30 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseRangeExpression parses `start..end` and `start..=end`, optionally
+// followed by `by step`. by is not a keyword and remains usable as a name.
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{Token: p.curToken, Start: start, Inclusive: p.curTokenIs(token.RANGEEQ)}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.End = p.parseExpression(precedence)
+	if exp.End == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "by" {
+		p.nextToken()
+		p.nextToken()
+		exp.Step = p.parseExpression(precedence)
+		if exp.Step == nil {
+			return nil
+		}
+	}
+
+	return exp
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionsList(token.RPAREN)
@@ -390,6 +757,17 @@ func (p *Parser) parseAccessExpression(array ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.DotExpression{Token: p.curToken, Left: left}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseExpressionsList(end token.TokenType) []ast.Expression {
 	exps := []ast.Expression{}
 
@@ -415,6 +793,13 @@ func (p *Parser) parseExpressionsList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	if target, ok := left.(*ast.AccessExpression); ok {
+		return p.parseIndexAssignExpression(target)
+	}
+	if target, ok := left.(*ast.DotExpression); ok {
+		return p.parseFieldAssignExpression(target)
+	}
+
 	exp := &ast.AssignExpression{Token: p.curToken}
 
 	name, ok := left.(*ast.Identifier)
@@ -439,6 +824,319 @@ func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseIndexAssignExpression(target *ast.AccessExpression) ast.Expression {
+	exp := &ast.IndexAssignExpression{Token: p.curToken, Target: target}
+
+	if p.curToken.Type != token.ASSIGN {
+		msg := fmt.Sprintf("[%d:%d] cannot declare %s, use = to assign to an index", p.curToken.Line, p.curToken.Column, target.String())
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	p.nextToken()
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		msg := "couldn't parse assigned expression"
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	exp.Value = value
+
+	return exp
+}
+
+func (p *Parser) parseFieldAssignExpression(target *ast.DotExpression) ast.Expression {
+	exp := &ast.FieldAssignExpression{Token: p.curToken, Target: target}
+
+	if p.curToken.Type != token.ASSIGN {
+		msg := fmt.Sprintf("[%d:%d] cannot declare %s, use = to assign to a field", p.curToken.Line, p.curToken.Column, target.String())
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	p.nextToken()
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		msg := "couldn't parse assigned expression"
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	exp.Value = value
+
+	return exp
+}
+
+// parseClassLiteral parses `class Name < Parent { fn method() { } ... }`.
+func (p *Parser) parseClassLiteral() ast.Expression {
+	exp := &ast.ClassLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.LT) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		exp.Parent = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+
+		if !p.curTokenIs(token.FUNCTION) || !p.peekTokenIs(token.IDENT) {
+			msg := fmt.Sprintf("[%d:%d] expected method declaration in class %s, got %q instead", p.curToken.Line, p.curToken.Column, exp.Name.Value, p.curToken.Type)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+
+		method, ok := p.parseFunctionDeclaration()
+		if !ok {
+			return nil
+		}
+		exp.Methods = append(exp.Methods, method)
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	exp := &ast.SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+
+	call, ok := p.parseExpression(PREFIX).(*ast.CallExpression)
+	if !ok {
+		msg := fmt.Sprintf("[%d:%d] spawn expects a function call", exp.Token.Line, exp.Token.Column)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	exp.Call = call
+
+	return exp
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	exp := &ast.SelectExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		p.nextToken()
+
+		sc := p.parseSelectCase()
+		if sc == nil {
+			return nil
+		}
+		exp.Cases = append(exp.Cases, sc)
+
+		if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	sc := &ast.SelectCase{Token: p.curToken}
+
+	head := p.parseExpression(LOWEST)
+	if assign, ok := head.(*ast.AssignExpression); ok && assign.Token.Type == token.DEFINE {
+		sc.Name = assign.Name
+		head = assign.Value
+	}
+
+	isDefault := false
+	switch head := head.(type) {
+	case *ast.Identifier:
+		isDefault = head.Value == "default" && sc.Name == nil
+	case *ast.CallExpression:
+		if fn, ok := head.Function.(*ast.Identifier); ok {
+			recv := fn.Value == "recv" && len(head.Arguments) == 1
+			send := fn.Value == "send" && len(head.Arguments) == 2 && sc.Name == nil
+			if recv || send {
+				sc.Operation = head
+			}
+		}
+	}
+
+	if sc.Operation == nil && !isDefault {
+		msg := fmt.Sprintf("[%d:%d] select case must be recv(ch), name := recv(ch), send(ch, value) or default", sc.Token.Line, sc.Token.Column)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	sc.Body = p.parseArmBody()
+	if sc.Body == nil {
+		return nil
+	}
+
+	return sc
+}
+
+// parseArmBody parses the right-hand side of =>, which is either a block or
+// a single expression.
+func (p *Parser) parseArmBody() *ast.BlockStatement {
+	p.nextToken()
+
+	if p.curTokenIs(token.LBRACE) {
+		block, ok := p.parseBlockStatement()
+		if !ok {
+			return nil
+		}
+		return block
+	}
+
+	stmt, ok := p.parseExpressionStatement()
+	if !ok {
+		return nil
+	}
+	return &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+}
+
+func (p *Parser) parseEnumLiteral() ast.Expression {
+	exp := &ast.EnumLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		variant := &ast.EnumVariant{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			variant.Fields = p.parseIdentifierList()
+			if variant.Fields == nil {
+				return nil
+			}
+		}
+		exp.Variants = append(exp.Variants, variant)
+
+		if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if len(exp.Variants) == 0 {
+		msg := fmt.Sprintf("[%d:%d] enum %s has no variants", exp.Token.Line, exp.Token.Column, exp.Name.Value)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseCaseExpression() ast.Expression {
+	exp := &ast.CaseExpression{Token: p.curToken}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+	if exp.Subject == nil {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) && !p.peekTokenIs(token.EOF) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		arm := &ast.CaseArm{Token: p.curToken, Variant: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			arm.Bindings = p.parseIdentifierList()
+			if arm.Bindings == nil {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+
+		arm.Body = p.parseArmBody()
+		if arm.Body == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
+// parseIdentifierList parses a parenthesised list of plain identifiers, as
+// used by enum variants and case patterns. It returns nil on error.
+func (p *Parser) parseIdentifierList() []*ast.Identifier {
+	idents := []*ast.Identifier{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return idents
+}
+
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
@@ -449,7 +1147,8 @@ const (
 	LOWEST
 	ASSIGN      // = := ::
 	EQUALS      // ==
-	LESSGREATER // < >
+	LESSGREATER // < > in
+	RANGE       // .. ..=
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -x !x
@@ -466,12 +1165,16 @@ var precedences = map[token.TokenType]int{
 	token.GT:       LESSGREATER,
 	token.LEQ:      LESSGREATER,
 	token.GEQ:      LESSGREATER,
+	token.IN:       LESSGREATER,
+	token.RANGE:    RANGE,
+	token.RANGEEQ:  RANGE,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: CALL, // TODO: maybe change to higher?
+	token.DOT:      CALL,
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -26,6 +26,13 @@ func TestOperatorPrecedence(t *testing.T) {
 		{"!true == !(false != true)", "((!true) == (!(false != true)))"},
 		{"a + add(b * c) * d", "(a + (add((b * c)) * d))"},
 		{"add(a, add(b, c / d), -f < g)", "add(a, add(b, (c / d)), ((-f) < g))"},
+		{"a..b + 1", "(a..(b + 1))"},
+		{"0..=n * 2 by -1", "(0..=(n * 2) by (-1))"},
+		{"x in 0..10 == true", "((x in (0..10)) == true)"},
+		{"by := 1; 0..by", "by := 1\n(0..by)"},
+		{"xs.map(f).sum()", "xs.map(f).sum()"},
+		{"-a.b + c.d(1)[0]", "((-a.b) + c.d(1)[0])"},
+		{"0..xs.len()", "(0..xs.len())"},
 	}
 
 	for i, tt := range tests {
@@ -109,6 +116,7 @@ func TestLiteralExpression(t *testing.T) {
 		expected interface{}
 	}{
 		{`5`, 5},
+		{`2.5`, 2.5},
 		{`"Hello, World!"`, "Hello, World!"},
 		{`true`, true},
 		{`false`, false},
@@ -252,6 +260,230 @@ func TestFunctionParameters(t *testing.T) {
 	}
 }
 
+func TestFunctionDeclaration(t *testing.T) {
+	program := testParse(t, "fn add(a, b: int): int { a + b }; add(1, 2)")
+
+	assertStatementsLen(t, program.Statements, 2)
+
+	decl, ok := program.Statements[0].(*ast.FunctionDeclaration)
+	if !ok {
+		t.Fatalf("statement is not ast.FunctionDeclaration, got %T", program.Statements[0])
+	}
+
+	testLiteralExpression(t, decl.Name, "add")
+	if len(decl.Function.Parameters) != 2 {
+		t.Fatalf("expected 2 parameters, got %d", len(decl.Function.Parameters))
+	}
+
+	expected := "fn add(a, b: int): int { (a + b) }"
+	if decl.String() != expected {
+		t.Errorf("wrong declaration; expected %q, got %q", expected, decl.String())
+	}
+
+	// without a name fn is still a function literal
+	program = testParse(t, "fn(x) { x }(1)")
+	assertStatementsLen(t, program.Statements, 1)
+	assertExpressionStatement(t, program.Statements[0])
+}
+
+func TestDeferStatement(t *testing.T) {
+	program := testParse(t, "fn() { defer close(ch); 1 }")
+
+	assertStatementsLen(t, program.Statements, 1)
+	stmt := assertExpressionStatement(t, program.Statements[0])
+
+	fn, ok := stmt.Expression.(*ast.FunctionExpression)
+	if !ok {
+		t.Fatalf("expression is not ast.FunctionExpression, got %T", stmt.Expression)
+	}
+	assertStatementsLen(t, fn.Body.Statements, 2)
+
+	deferred, ok := fn.Body.Statements[0].(*ast.DeferStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.DeferStatement, got %T", fn.Body.Statements[0])
+	}
+	if deferred.String() != "defer close(ch);" {
+		t.Errorf("wrong statement; expected %q, got %q", "defer close(ch);", deferred.String())
+	}
+
+	p := New(lexer.New("defer close(ch)"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 || p.Errors()[0] != "[1:1] defer outside of function" {
+		t.Errorf("expected defer outside of function error, got %v", p.Errors())
+	}
+}
+
+func TestYieldExpression(t *testing.T) {
+	tests := []struct {
+		input     string
+		generator bool
+	}{
+		{"fn() { yield 1 }", true},
+		{"fn() { fn() { yield 1 } }", false},
+		{"fn() { 1 }", false},
+	}
+
+	for i, tt := range tests {
+		program := testParse(t, tt.input)
+
+		assertStatementsLen(t, program.Statements, 1)
+		stmt := assertExpressionStatement(t, program.Statements[0])
+
+		function, ok := stmt.Expression.(*ast.FunctionExpression)
+		if !ok {
+			t.Fatalf("[%d] expression is not ast.FunctionExpression, got %T", i, stmt.Expression)
+		}
+
+		if function.Generator != tt.generator {
+			t.Errorf("[%d] function.Generator expected %v, got %v", i, tt.generator, function.Generator)
+		}
+	}
+
+	program := testParse(t, "fn() { fn() { yield 1 } }")
+	outer := assertExpressionStatement(t, program.Statements[0]).Expression.(*ast.FunctionExpression)
+	inner := assertExpressionStatement(t, outer.Body.Statements[0]).Expression.(*ast.FunctionExpression)
+	if !inner.Generator {
+		t.Errorf("inner function.Generator expected true, got false")
+	}
+
+	p := New(lexer.New("yield 1"))
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected error for yield outside of function")
+	}
+}
+
+func TestConcurrencyExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"spawn f(1, 2)", "spawn f(1, 2)"},
+		{"t := spawn fn(x) { x }(1)", "t := spawn fn(x) { x }(1)"},
+		{"select { v := recv(a) => v, send(b, 1) => { 2 }; default => 3 }", "select { v := recv(a) => { v }, send(b, 1) => { 2 }, default => { 3 } }"},
+	}
+
+	for i, tt := range tests {
+		prog := testParse(t, tt.input)
+		assertStatementsLen(t, prog.Statements, 1)
+
+		if prog.String() != tt.expected {
+			t.Errorf("[%d] wrong program; expected %q, got %q", i, tt.expected, prog.String())
+		}
+	}
+
+	invalid := []string{
+		"spawn 1",
+		"select { f(a) => 1 }",
+		"select { v := send(a, 1) => 1 }",
+		"select { recv(a) 1 }",
+	}
+
+	for i, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
+func TestEnumAndCase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"enum Shape { Circle(r), Rect(w, h), Empty }", "enum Shape { Circle(r), Rect(w, h), Empty }"},
+		{"enum Unit { Unit() }", "enum Unit { Unit() }"},
+		{"case s { Circle(r) => r, Rect(w, h) => { w * h }; _ => 0 }", "case s { Circle(r) => { r }, Rect(w, h) => { (w * h) }, _ => { 0 } }"},
+		{"case f(x) { Empty => 1 }", "case f(x) { Empty => { 1 } }"},
+	}
+
+	for i, tt := range tests {
+		prog := testParse(t, tt.input)
+		assertStatementsLen(t, prog.Statements, 1)
+
+		if prog.String() != tt.expected {
+			t.Errorf("[%d] wrong program; expected %q, got %q", i, tt.expected, prog.String())
+		}
+	}
+
+	invalid := []string{
+		"enum { A }",
+		"enum E {}",
+		"enum E { A(1) }",
+		"case x { 1 => 2 }",
+		"case x { A(b) 1 }",
+	}
+
+	for i, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
+func TestClassLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"class A { }", "class A {  }"},
+		{"class B < A { fn init(x) { self.x = x }; fn get() { self.x } }", "class B < A { fn init(x) { self.x = x }; fn get() { self.x } }"},
+		{"self.items[0] = 1", "self.items[0] = 1"},
+	}
+
+	for i, tt := range tests {
+		prog := testParse(t, tt.input)
+		assertStatementsLen(t, prog.Statements, 1)
+
+		if prog.String() != tt.expected {
+			t.Errorf("[%d] wrong program; expected %q, got %q", i, tt.expected, prog.String())
+		}
+	}
+
+	invalid := []string{
+		"class { }",
+		"class A < { }",
+		"class A { x := 1 }",
+		"class A { fn() { 1 } }",
+		"self.x := 1",
+	}
+
+	for i, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
+func TestMacroLiteral(t *testing.T) {
+	program := testParse(t, "macro(x, y) { x + y; }")
+
+	assertStatementsLen(t, program.Statements, 1)
+	stmt := assertExpressionStatement(t, program.Statements[0])
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("expression is not ast.MacroLiteral, got %T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("expected 2 parameters, got %d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	assertStatementsLen(t, macro.Body.Statements, 1)
+	body := assertExpressionStatement(t, macro.Body.Statements[0])
+	testInfixExpression(t, body.Expression, "x", "+", "y")
+}
+
 func TestCallExpression(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -317,6 +549,13 @@ func TestAssignment(t *testing.T) {
 	}{
 		{"foo := 3", "foo := 3"},
 		{"bar = 4", "bar = 4"},
+		{"foo[0] = 5", "foo[0] = 5"},
+		{"foo[1][2] = bar", "foo[1][2] = bar"},
+		{"foo: int := 3", "foo: int := 3"},
+		{"foo: [str] :: bar", "foo: [str] :: bar"},
+		{"foo: fn(int, [bool]): str :: bar", "foo: fn(int, [bool]): str :: bar"},
+		{"f :: fn(a: int, b): str { a }", "f :: fn(a: int, b): str { a }"},
+		{"fn(f: fn(int)): void { f(1) }", "fn(f: fn(int)): void { f(1) }"},
 	}
 
 	for i, tt := range tests {
@@ -330,6 +569,23 @@ func TestAssignment(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []string{
+		"foo: int = 3",
+		"foo: := 3",
+		"foo: [int := 3",
+		"fn(a: 1) { a }",
+	}
+
+	for i, input := range tests {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
 func TestArrayLiteral(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -359,6 +615,83 @@ func TestArrayLiteral(t *testing.T) {
 	}
 }
 
+func TestMapLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m :: {}`, `m :: {}`},
+		{`m :: {"a": 1, b: [2], "c" + "d": {"e": f(x)}}`, `m :: {"a": 1, b: [2], ("c" + "d"): {"e": f(x)}}`},
+		{`f({"a": 1})["a"]`, `f({"a": 1})["a"]`},
+		// braces starting a statement are a block unless a string key and a
+		// colon follow
+		{`{ x }`, `x`},
+		{`{ "x" }`, `"x"`},
+		{`{"a": 1, "b": 2}["a"]`, `{"a": 1, "b": 2}["a"]`},
+	}
+
+	for i, tt := range tests {
+		prog := testParse(t, tt.input)
+		assertStatementsLen(t, prog.Statements, 1)
+
+		if prog.String() != tt.expected {
+			t.Errorf("[%d] wrong program; expected %q, got %q", i, tt.expected, prog.String())
+		}
+	}
+
+	invalid := []string{
+		`m :: {"a"}`,
+		`m :: {"a": 1,}`,
+		`m :: {"a": 1 "b": 2}`,
+		`m :: {"a": 1`,
+	}
+
+	for i, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
+func TestComprehension(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x * 2 for x in xs if x > 1]", "[(x * 2) for x in xs if (x > 1)]"},
+		{"[f(c) for c in \"abc\"]", "[f(c) for c in \"abc\"]"},
+		{"[[x, 1] for x in 0..n]", "[[x, 1] for x in (0..n)]"},
+	}
+
+	for i, tt := range tests {
+		prog := testParse(t, tt.input)
+		assertStatementsLen(t, prog.Statements, 1)
+
+		if prog.String() != tt.expected {
+			t.Errorf("[%d] wrong program; expected %q, got %q", i, tt.expected, prog.String())
+		}
+	}
+
+	invalid := []string{
+		"xs.1",
+		"[x for 1 in xs]",
+		"[x for x xs]",
+		"[x for x in xs if]",
+		"[x for x in xs, y]",
+		"[x, y for x in xs]",
+	}
+
+	for i, input := range invalid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%d] expected parser errors for %q", i, input)
+		}
+	}
+}
+
 func TestAccessExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -443,6 +776,21 @@ func testIntegerLiteral(t *testing.T, exp ast.Expression, value int64) bool {
 	return true
 }
 
+func testFloatLiteral(t *testing.T, exp ast.Expression, value float64) bool {
+	fl, ok := exp.(*ast.FloatLiteral)
+	if !ok {
+		t.Errorf("exp not *ast.FloatLiteral, got %T", exp)
+		return false
+	}
+
+	if fl.Value != value {
+		t.Errorf("fl.Value not %g, got %g", value, fl.Value)
+		return false
+	}
+
+	return true
+}
+
 func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
 	ident, ok := exp.(*ast.Identifier)
 	if !ok {
@@ -511,6 +859,8 @@ func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{
 		} else {
 			return testStringLiteral(t, exp, v)
 		}
+	case float64:
+		return testFloatLiteral(t, exp, v)
 	case bool:
 		return testBoolean(t, exp, v)
 	default:
@@ -1,7 +1,6 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 
@@ -25,20 +24,23 @@ var mode int = EVAL
 
 // TODO: implement history, persistent state, real-time eval, pretty printing?
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
+	// programs read input from the same reader as the REPL
+	env.Runtime().SetStdin(in)
+	env.Runtime().Stdout = out
+	defer env.Runtime().Close()
 
 	fmt.Println("[REPL Mode]")
 
 	for {
 		fmt.Printf("%s", PROMPT)
 
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := env.Runtime().ReadLine()
+		if err != nil {
 			return
 		}
 
-		line := scanner.Text()
 		switch line {
 		case "lex":
 			mode = LEX
@@ -72,7 +74,10 @@ func Start(in io.Reader, out io.Writer) {
 			p := parser.New(l)
 			program := p.ParseProgram()
 			printErrors(p.Errors())
-			result := evaluator.Eval(program, env)
+			evaluator.DefineMacros(program, macroEnv)
+			expanded, errors := evaluator.ExpandMacros(program, macroEnv)
+			printErrors(errors)
+			result := evaluator.Eval(expanded, env)
 			if result == nil {
 				fmt.Println("could not evaluate")
 			} else {
@@ -16,6 +16,7 @@ const (
 	// Identifier & Literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
 	// Operators
@@ -33,10 +34,15 @@ const (
 	GEQ      = ">="
 	EQ       = "=="
 	NEQ      = "!="
+	ARROW    = "=>"
+	RANGE    = ".."
+	RANGEEQ  = "..="
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -52,6 +58,16 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
+	SELECT   = "SELECT"
+	MACRO    = "MACRO"
+	ENUM     = "ENUM"
+	CASE     = "CASE"
+	IN       = "IN"
+	FOR      = "FOR"
+	DEFER    = "DEFER"
+	CLASS    = "CLASS"
 )
 
 var keywords = map[string]TokenType{
@@ -61,6 +77,16 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"yield":  YIELD,
+	"spawn":  SPAWN,
+	"select": SELECT,
+	"macro":  MACRO,
+	"enum":   ENUM,
+	"case":   CASE,
+	"in":     IN,
+	"for":    FOR,
+	"defer":  DEFER,
+	"class":  CLASS,
 }
 
 func LookupIdentifier(ident string) TokenType {